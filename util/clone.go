@@ -0,0 +1,223 @@
+package util
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Cloner is implemented by types that copy themselves. DeepCopy uses the
+// result of Clone, which must be of the receiver's type or a pointer to it,
+// instead of copying the value field by field.
+type Cloner interface {
+	Clone() interface{}
+}
+
+var clonerType = reflect.TypeOf((*Cloner)(nil)).Elem()
+
+// DeepCopy returns a deep copy of v. Pointers, slices, maps, arrays,
+// interfaces and structs, including their unexported fields, are copied
+// recursively. References shared within v are shared within the copy, so
+// cyclic values are copied faithfully. Funcs and channels are not copied.
+// time.Time values are assigned as they are, sync/atomic values are copied
+// through their Load and Store methods, and values of the other sync types,
+// such as mutexes, are left zero in the copy.
+func DeepCopy[T any](v T) T {
+	var out T
+	reflect.ValueOf(&out).Elem().Set(deepCopyValue(reflect.ValueOf(&v).Elem()))
+	return out
+}
+
+// copier deep-copies values, remembering copied pointers, slices and maps so
+// that shared references stay shared and cycles terminate.
+type copier struct {
+	copies map[copyKey]reflect.Value
+}
+
+type copyKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+func newCopier() *copier {
+	return &copier{copies: map[copyKey]reflect.Value{}}
+}
+
+// deepCopyValue returns an addressable deep copy of v.
+func deepCopyValue(v reflect.Value) reflect.Value {
+	dst := reflect.New(v.Type()).Elem()
+	newCopier().copyInto(dst, v)
+	return dst
+}
+
+// copyInto deep-copies src into the settable dst of the same type.
+func (c *copier) copyInto(dst, src reflect.Value) {
+	if c.copyCloner(dst, src) || c.copyOpaque(dst, src) {
+		return
+	}
+
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		key := copyKey{src.Pointer(), src.Type(), 0}
+		if cp, ok := c.copies[key]; ok {
+			dst.Set(cp)
+			return
+		}
+		cp := reflect.New(src.Type().Elem())
+		c.copies[key] = cp
+		c.copyInto(cp.Elem(), src.Elem())
+		dst.Set(cp)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		elem := reflect.New(src.Elem().Type()).Elem()
+		c.copyInto(elem, src.Elem())
+		dst.Set(elem)
+	case reflect.Struct:
+		// go through unsafe so unexported fields are copied too
+		if !src.CanAddr() {
+			addressable := reflect.New(src.Type()).Elem()
+			addressable.Set(src)
+			src = addressable
+		}
+		for i := 0; i < src.NumField(); i++ {
+			c.copyInto(settableField(dst, i), settableField(src, i))
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		key := copyKey{src.Pointer(), src.Type(), src.Len()}
+		if cp, ok := c.copies[key]; ok {
+			dst.Set(cp)
+			return
+		}
+		cp := reflect.MakeSlice(src.Type(), src.Len(), src.Cap())
+		c.copies[key] = cp
+		for i := 0; i < src.Len(); i++ {
+			c.copyInto(cp.Index(i), src.Index(i))
+		}
+		dst.Set(cp)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			c.copyInto(dst.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		key := copyKey{src.Pointer(), src.Type(), 0}
+		if cp, ok := c.copies[key]; ok {
+			dst.Set(cp)
+			return
+		}
+		cp := reflect.MakeMapWithSize(src.Type(), src.Len())
+		c.copies[key] = cp
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(src.Type().Key()).Elem()
+			c.copyInto(k, iter.Key())
+			elem := reflect.New(src.Type().Elem()).Elem()
+			c.copyInto(elem, iter.Value())
+			cp.SetMapIndex(k, elem)
+		}
+		dst.Set(cp)
+	default:
+		// scalars are copied by value; funcs, chans and unsafe pointers are shared
+		dst.Set(src)
+	}
+}
+
+// copyOpaque handles the values DeepCopy does not look inside, reporting
+// whether src was one.
+func (c *copier) copyOpaque(dst, src reflect.Value) bool {
+	switch pkg := src.Type().PkgPath(); {
+	case src.Type() == timeType:
+		// keeps the *Location shared, so copies compare == to the original
+		dst.Set(src)
+		return true
+	case pkg == "sync/atomic":
+		c.copyAtomic(dst, src)
+		return true
+	case pkg == "sync":
+		// lock and wait group state belongs to the original
+		return true
+	}
+	return false
+}
+
+// copyAtomic copies the sync/atomic value src through the Load and Store
+// methods every atomic type has.
+func (c *copier) copyAtomic(dst, src reflect.Value) {
+	if !src.CanAddr() {
+		addressable := reflect.New(src.Type()).Elem()
+		addressable.Set(src)
+		src = addressable
+	}
+	load, store := src.Addr().MethodByName("Load"), dst.Addr().MethodByName("Store")
+	if !load.IsValid() || !store.IsValid() {
+		return
+	}
+	loaded := load.Call(nil)[0]
+	if (loaded.Kind() == reflect.Interface || loaded.Kind() == reflect.Ptr) && loaded.IsNil() {
+		// an atomic.Value cannot store nil, and the copy is already empty
+		return
+	}
+	cp := reflect.New(loaded.Type()).Elem()
+	c.copyInto(cp, loaded)
+	store.Call([]reflect.Value{cp})
+}
+
+// copyCloner copies src through its Clone method, reporting whether it could.
+func (c *copier) copyCloner(dst, src reflect.Value) bool {
+	var cloner Cloner
+	switch {
+	case src.Kind() == reflect.Interface:
+		return false
+	case src.Type().Implements(clonerType):
+		if src.Kind() == reflect.Ptr {
+			if src.IsNil() {
+				return false
+			}
+			key := copyKey{src.Pointer(), src.Type(), 0}
+			if cp, ok := c.copies[key]; ok {
+				dst.Set(cp)
+				return true
+			}
+		}
+		cloner = src.Interface().(Cloner)
+	case src.CanAddr() && src.Addr().Type().Implements(clonerType):
+		cloner = src.Addr().Interface().(Cloner)
+	default:
+		return false
+	}
+
+	cloned := reflect.ValueOf(cloner.Clone())
+	switch {
+	case !cloned.IsValid():
+		return false
+	case cloned.Type() == dst.Type():
+		dst.Set(cloned)
+	case cloned.Kind() == reflect.Ptr && cloned.Type().Elem() == dst.Type() && !cloned.IsNil():
+		dst.Set(cloned.Elem())
+	default:
+		return false
+	}
+	if src.Kind() == reflect.Ptr {
+		c.copies[copyKey{src.Pointer(), src.Type(), 0}] = dst
+	}
+	return true
+}
+
+// settableField returns field i of the addressable struct v, even if it is unexported.
+func settableField(v reflect.Value, i int) reflect.Value {
+	field := v.Field(i)
+	if field.CanSet() {
+		return field
+	}
+	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+}
@@ -0,0 +1,116 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type cloneNode struct {
+	Name     string
+	Children []*cloneNode
+	Parent   *cloneNode
+	Attrs    map[string]interface{}
+	Grid     [2][]int
+	secret   []string
+}
+
+type countingCloner struct {
+	Value  int
+	cloned *int
+}
+
+func (c countingCloner) Clone() interface{} {
+	*c.cloned++
+	return countingCloner{Value: c.Value * 10, cloned: c.cloned}
+}
+
+type ptrCloner struct {
+	Value int
+}
+
+func (p *ptrCloner) Clone() interface{} {
+	return &ptrCloner{Value: -p.Value}
+}
+
+type cloneGuarded struct {
+	mu      sync.Mutex
+	Created time.Time
+	Counts  map[string]int
+	Hits    atomic.Int64
+	Last    atomic.Value
+	Next    atomic.Pointer[cloneGuarded]
+	Empty   atomic.Value
+}
+
+func TestDeepCopy(t *testing.T) {
+	Convey("When calling DeepCopy", t, func() {
+		root := &cloneNode{
+			Name:   "root",
+			Attrs:  map[string]interface{}{"list": []interface{}{1, "a"}, "nested": map[string]interface{}{"k": "v"}},
+			Grid:   [2][]int{{1}, {2, 3}},
+			secret: []string{"s"},
+		}
+		child := &cloneNode{Name: "child", Parent: root}
+		root.Children = []*cloneNode{child, child}
+
+		cp := DeepCopy(root)
+
+		Convey("The copy should be equal but share no memory", func() {
+			So(cp, ShouldNotPointTo, root)
+			So(cp.Name, ShouldEqual, "root")
+			So(cp.Children[0], ShouldNotPointTo, child)
+			cp.Attrs["nested"].(map[string]interface{})["k"] = "changed"
+			cp.Attrs["list"].([]interface{})[0] = 2
+			cp.Grid[1][0] = 9
+			So(root.Attrs["nested"], ShouldResemble, map[string]interface{}{"k": "v"})
+			So(root.Attrs["list"], ShouldResemble, []interface{}{1, "a"})
+			So(root.Grid[1][0], ShouldEqual, 2)
+		})
+		Convey("Shared references and cycles should be preserved", func() {
+			So(cp.Children[0], ShouldPointTo, cp.Children[1])
+			So(cp.Children[0].Parent, ShouldPointTo, cp)
+		})
+		Convey("Unexported fields should be copied", func() {
+			So(cp.secret, ShouldResemble, []string{"s"})
+			cp.secret[0] = "t"
+			So(root.secret[0], ShouldEqual, "s")
+		})
+		Convey("Non-pointer values and nil interfaces should be copied", func() {
+			So(DeepCopy(*root).Attrs, ShouldResemble, root.Attrs)
+			var err error
+			So(DeepCopy(err), ShouldBeNil)
+		})
+		Convey("Cloners should copy themselves", func() {
+			count := 0
+			vals := DeepCopy([]countingCloner{{1, &count}, {2, &count}})
+			So(vals[1].Value, ShouldEqual, 20)
+			So(count, ShouldEqual, 2)
+
+			ptrs := DeepCopy(map[string]*ptrCloner{"a": {3}})
+			So(ptrs["a"].Value, ShouldEqual, -3)
+			So(DeepCopy(ptrCloner{4}).Value, ShouldEqual, -4)
+		})
+		Convey("Times should be assigned, atomics loaded and locks left zero", func() {
+			g := &cloneGuarded{Created: time.Now(), Counts: map[string]int{"a": 1}}
+			g.Hits.Store(42)
+			g.Last.Store([]string{"x"})
+			g.Next.Store(g)
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			cp := DeepCopy(g)
+			So(cp.Created == g.Created, ShouldBeTrue)
+			So(cp.Counts, ShouldResemble, g.Counts)
+			So(cp.mu.TryLock(), ShouldBeTrue)
+			So(cp.Hits.Load(), ShouldEqual, 42)
+			So(cp.Last.Load(), ShouldResemble, []string{"x"})
+			So(cp.Next.Load(), ShouldPointTo, cp)
+			So(cp.Empty.Load(), ShouldBeNil)
+			g.Hits.Add(1)
+			So(cp.Hits.Load(), ShouldEqual, 42)
+		})
+	})
+}
@@ -0,0 +1,193 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v1"
+)
+
+// Codec converts between a config file format and Go values.
+type Codec interface {
+	Unmarshal(data []byte, v interface{}) error
+	Marshal(v interface{}) ([]byte, error)
+}
+
+// CodecDetector is a Codec that can recognize its format from a file's
+// content, for files whose extension has no codec registered.
+type CodecDetector interface {
+	Codec
+	Detect(data []byte) bool
+}
+
+type registeredCodec struct {
+	name       string
+	codec      Codec
+	extensions []string
+}
+
+var (
+	codecsMu sync.RWMutex
+	// codecs in the order their formats are detected, most specific first;
+	// YAML accepts most documents, so it comes last
+	codecs = []*registeredCodec{
+		{"json", jsonCodec{}, []string{".json"}},
+		{"toml", tomlCodec{}, []string{".toml"}},
+		{"dotenv", dotenvCodec{}, []string{".env"}},
+		{"yaml", yamlCodec{}, []string{".yaml", ".yml"}},
+	}
+)
+
+// RegisterCodec makes codec available to ReadConfigFile and WriteConfigFile
+// under name, for files with the given extensions (such as ".ini"). A codec
+// registered under an existing name replaces it, and extensions are taken
+// over from codecs registered before. Registered codecs are detected before
+// the built-in ones.
+func RegisterCodec(name string, codec Codec, extensions ...string) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	exts := make([]string, len(extensions))
+	for i, ext := range extensions {
+		exts[i] = strings.ToLower(ext)
+	}
+	var kept []*registeredCodec
+	for _, c := range codecs {
+		if c.name == name {
+			continue
+		}
+		var others []string
+		for _, ext := range c.extensions {
+			if !SliceContains(exts, ext) {
+				others = append(others, ext)
+			}
+		}
+		kept = append(kept, &registeredCodec{c.name, c.codec, others})
+	}
+	codecs = append([]*registeredCodec{{name, codec, exts}}, kept...)
+}
+
+// LookupCodec returns the codec registered under name.
+func LookupCodec(name string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	for _, c := range codecs {
+		if c.name == name {
+			return c.codec, true
+		}
+	}
+	return nil, false
+}
+
+// codecForExt returns the codec registered for the extension of path.
+func codecForExt(path string) Codec {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == "" {
+		return nil
+	}
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	for _, c := range codecs {
+		if SliceContains(c.extensions, ext) {
+			return c.codec
+		}
+	}
+	return nil
+}
+
+// detectCodec returns the first codec that recognizes data.
+func detectCodec(data []byte) Codec {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	for _, c := range codecs {
+		if d, ok := c.codec.(CodecDetector); ok && d.Detect(data) {
+			return c.codec
+		}
+	}
+	return nil
+}
+
+// ReadConfigFile reads the file at path into result with the codec
+// registered for its extension (".json", ".toml", ".env", ".yaml" or ".yml"
+// unless others are registered) or, failing that, the codec that detects its
+// content.
+func ReadConfigFile(path string, result interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	codec := codecForExt(path)
+	if codec == nil {
+		if codec = detectCodec(data); codec == nil {
+			return fmt.Errorf("unrecognized config file format")
+		}
+	}
+	return codec.Unmarshal(data, result)
+}
+
+// WriteConfigFile writes data to the file at path with the codec registered
+// for its extension or, failing that, the codec that detects the file's
+// current content.
+func WriteConfigFile(path string, data interface{}, perm os.FileMode) error {
+	codec := codecForExt(path)
+	if codec == nil {
+		if existing, err := ioutil.ReadFile(path); err == nil {
+			codec = detectCodec(existing)
+		}
+		if codec == nil {
+			return fmt.Errorf("no codec for config file type %q", filepath.Ext(path))
+		}
+	}
+	raw, err := codec.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, raw, perm)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// Marshal writes "pretty" JSON, as WriteJsonToFile does.
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "    "); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (jsonCodec) Detect(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && (data[0] == '{' || data[0] == '[') && json.Valid(data)
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
+
+func (yamlCodec) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+// Detect accepts documents that are a mapping, as config files are; most
+// other text is a valid YAML scalar.
+func (yamlCodec) Detect(data []byte) bool {
+	var m map[string]interface{}
+	return yaml.Unmarshal(data, &m) == nil && m != nil
+}
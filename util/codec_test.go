@@ -0,0 +1,87 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type codecConfig struct {
+	Name string `json:"name" yaml:"name"`
+	Port int    `json:"port" yaml:"port"`
+}
+
+// upperCodec stores a config as "NAME PORT".
+type upperCodec struct{}
+
+func (upperCodec) Unmarshal(data []byte, v interface{}) error {
+	fields := strings.Fields(string(data))
+	return Decode(map[string]interface{}{"name": strings.ToLower(fields[0]), "port": fields[1]}, v, DecodeOptions{WeaklyTyped: true})
+}
+
+func (upperCodec) Marshal(v interface{}) ([]byte, error) {
+	c := v.(*codecConfig)
+	return []byte(strings.ToUpper(c.Name) + " " + strconv.Itoa(c.Port)), nil
+}
+
+func TestConfigFiles(t *testing.T) {
+	Convey("With a temporary directory", t, func() {
+		dir, err := ioutil.TempDir("", "codec")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		write := func(name, content string) string {
+			path := filepath.Join(dir, name)
+			So(ioutil.WriteFile(path, []byte(content), 0644), ShouldBeNil)
+			return path
+		}
+
+		Convey("Files should be read by extension", func() {
+			var c codecConfig
+			So(ReadConfigFile(write("a.JSON", `{"name": "a", "port": 1}`), &c), ShouldBeNil)
+			So(c, ShouldResemble, codecConfig{"a", 1})
+			So(ReadConfigFile(write("b.yml", "name: b\nport: 2\n"), &c), ShouldBeNil)
+			So(c, ShouldResemble, codecConfig{"b", 2})
+		})
+
+		Convey("Files without a known extension should be detected by content", func() {
+			var c codecConfig
+			So(ReadConfigFile(write("c", `{"name": "c"}`), &c), ShouldBeNil)
+			So(c.Name, ShouldEqual, "c")
+			So(ReadConfigFile(write("d.conf", "name: d\n"), &c), ShouldBeNil)
+			So(c.Name, ShouldEqual, "d")
+			So(ReadConfigFile(write("e", "just text"), &c), ShouldNotBeNil)
+		})
+
+		Convey("Written files should read back the same", func() {
+			path := filepath.Join(dir, "out.yaml")
+			So(WriteConfigFile(path, codecConfig{"w", 3}, 0644), ShouldBeNil)
+			var c codecConfig
+			So(ReadYamlFromFile(path, &c), ShouldBeNil)
+			So(c, ShouldResemble, codecConfig{"w", 3})
+			So(WriteConfigFile(filepath.Join(dir, "out.unknown"), c, 0644), ShouldNotBeNil)
+		})
+
+		Convey("Registered codecs should be used for their extensions", func() {
+			RegisterCodec("upper", upperCodec{}, ".upper")
+			defer func() {
+				codecsMu.Lock()
+				codecs = codecs[1:]
+				codecsMu.Unlock()
+			}()
+			path := filepath.Join(dir, "f.upper")
+			So(WriteConfigFile(path, &codecConfig{"f", 4}, 0644), ShouldBeNil)
+			data, _ := ioutil.ReadFile(path)
+			So(string(data), ShouldEqual, "F 4")
+			var c codecConfig
+			So(ReadConfigFile(path, &c), ShouldBeNil)
+			So(c, ShouldResemble, codecConfig{"f", 4})
+			_, ok := LookupCodec("upper")
+			So(ok, ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,267 @@
+package util
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// StringCoercer parses strings, such as flag or environment variable values,
+// into values of arbitrary Go types.
+type StringCoercer struct {
+	// TimeLayouts are tried in order when parsing a time.Time.
+	TimeLayouts []string
+	// SliceSeparator splits slice and array values, e.g. "a,b,c".
+	SliceSeparator string
+	// MapSeparator splits map entries, e.g. "k1=v1,k2=v2".
+	MapSeparator string
+	// KeyValueSeparator splits a map entry into its key and value.
+	KeyValueSeparator string
+}
+
+// DefaultStringCoercer is used by SetPathFromString and the other string setters.
+var DefaultStringCoercer = NewStringCoercer()
+
+func NewStringCoercer() *StringCoercer {
+	return &StringCoercer{
+		TimeLayouts: []string{
+			time.RFC3339Nano,
+			"2006-01-02T15:04:05",
+			"2006-01-02 15:04:05",
+			"2006-01-02",
+		},
+		SliceSeparator:    ",",
+		MapSeparator:      ",",
+		KeyValueSeparator: "=",
+	}
+}
+
+// SetPathFromString parses s into the type of the field at path and sets it,
+// e.g. SetPathFromString(&cfg, "Server.Port", "8080").
+func SetPathFromString(obj interface{}, path string, s string) error {
+	return DefaultStringCoercer.SetPath(obj, path, s)
+}
+
+// CoerceString parses s into a value of type t using DefaultStringCoercer.
+func CoerceString(s string, t reflect.Type) (reflect.Value, error) {
+	return DefaultStringCoercer.Coerce(s, t)
+}
+
+// SetPath parses s into the type of the field at path within obj and sets it.
+// obj must be a pointer; nil pointers along the path are allocated the same
+// way SetNestedStructIndex does.
+func (c *StringCoercer) SetPath(obj interface{}, path string, s string) error {
+	segs, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	root, err := settableRoot(obj)
+	if err != nil {
+		return err
+	}
+	return updatePath(root, segs, fieldByGoName, func(dst reflect.Value) error {
+		val, err := c.Coerce(s, dst.Type())
+		if err != nil {
+			return err
+		}
+		dst.Set(val)
+		return nil
+	})
+}
+
+// Coerce parses s into a value of type t. Supported are strings, bools, ints,
+// uints, floats, time.Duration, time.Time, pointers to any of those, types
+// implementing encoding.TextUnmarshaler, separated slices and arrays, and
+// separated key/value maps.
+func (c *StringCoercer) Coerce(s string, t reflect.Type) (reflect.Value, error) {
+	switch t {
+	case durationType:
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return reflect.Value{}, coerceError(s, t, err)
+		}
+		return reflect.ValueOf(d), nil
+	case timeType:
+		return c.coerceTime(s)
+	}
+
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		v := reflect.New(t)
+		if err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return reflect.Value{}, coerceError(s, t, err)
+		}
+		return v.Elem(), nil
+	}
+
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem, err := c.Coerce(s, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return reflect.Value{}, coerceError(s, t, err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 0, t.Bits())
+		if err != nil {
+			return reflect.Value{}, coerceError(s, t, err)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(strings.TrimSpace(s), 0, t.Bits())
+		if err != nil {
+			return reflect.Value{}, coerceError(s, t, err)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), t.Bits())
+		if err != nil {
+			return reflect.Value{}, coerceError(s, t, err)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s))
+			return v, nil
+		}
+		parts := c.split(s, c.SliceSeparator)
+		v.Set(reflect.MakeSlice(t, 0, len(parts)))
+		for _, part := range parts {
+			elem, err := c.Coerce(part, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Set(reflect.Append(v, elem))
+		}
+	case reflect.Array:
+		parts := c.split(s, c.SliceSeparator)
+		if len(parts) > t.Len() {
+			return reflect.Value{}, coerceError(s, t, fmt.Errorf("too many elements (%d)", len(parts)))
+		}
+		for i, part := range parts {
+			elem, err := c.Coerce(part, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Index(i).Set(elem)
+		}
+	case reflect.Map:
+		v.Set(reflect.MakeMap(t))
+		for _, entry := range c.split(s, c.MapSeparator) {
+			kv := strings.SplitN(entry, c.KeyValueSeparator, 2)
+			if len(kv) != 2 {
+				return reflect.Value{}, coerceError(s, t, fmt.Errorf("entry %q is not of the form key%svalue", entry, c.KeyValueSeparator))
+			}
+			key, err := c.Coerce(strings.TrimSpace(kv[0]), t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			val, err := c.Coerce(kv[1], t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.SetMapIndex(key, val)
+		}
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return reflect.Value{}, coerceError(s, t, fmt.Errorf("unsupported type"))
+		}
+		v.Set(reflect.ValueOf(s))
+	default:
+		return reflect.Value{}, coerceError(s, t, fmt.Errorf("unsupported type"))
+	}
+	return v, nil
+}
+
+// Format renders v as a string that Coerce parses back into the same value.
+func (c *StringCoercer) Format(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	switch v.Type() {
+	case durationType:
+		return v.Interface().(time.Duration).String()
+	case timeType:
+		layout := time.RFC3339Nano
+		if len(c.TimeLayouts) > 0 {
+			layout = c.TimeLayouts[0]
+		}
+		return v.Interface().(time.Time).Format(layout)
+	}
+	if v.Type().Implements(textMarshalerType) && (v.Kind() != reflect.Ptr || !v.IsNil()) {
+		if text, err := v.Interface().(encoding.TextMarshaler).MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return ""
+		}
+		return c.Format(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes())
+		}
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = c.Format(v.Index(i))
+		}
+		return strings.Join(parts, c.SliceSeparator)
+	case reflect.Map:
+		keys := sortedMapKeys(v)
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			parts[i] = c.Format(key) + c.KeyValueSeparator + c.Format(v.MapIndex(key))
+		}
+		return strings.Join(parts, c.MapSeparator)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func (c *StringCoercer) coerceTime(s string) (reflect.Value, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range c.TimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return reflect.ValueOf(t), nil
+		}
+	}
+	return reflect.Value{}, coerceError(s, timeType, fmt.Errorf("no matching layout in %q", c.TimeLayouts))
+}
+
+// split separates s on sep, trimming surrounding whitespace. An empty (or
+// all-whitespace) s yields no parts.
+func (c *StringCoercer) split(s, sep string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	parts := strings.Split(s, sep)
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+func coerceError(s string, t reflect.Type, err error) error {
+	return fmt.Errorf("cannot parse %q as %s: %v", s, t, err)
+}
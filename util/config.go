@@ -0,0 +1,267 @@
+package util
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// ConfigLayer identifies where a ConfigLoader took a value from.
+type ConfigLayer int
+
+const (
+	LayerDefault ConfigLayer = iota
+	LayerFile
+	LayerEnv
+	LayerFlag
+)
+
+func (l ConfigLayer) String() string {
+	switch l {
+	case LayerDefault:
+		return "default"
+	case LayerFile:
+		return "file"
+	case LayerEnv:
+		return "env"
+	case LayerFlag:
+		return "flag"
+	}
+	return fmt.Sprintf("ConfigLayer(%d)", int(l))
+}
+
+// ConfigSource is a single place a value was set from: the file, variable
+// or flag Name within Layer.
+type ConfigSource struct {
+	Layer ConfigLayer
+	Name  string
+}
+
+func (s ConfigSource) String() string {
+	switch {
+	case s.Name == "":
+		return s.Layer.String()
+	case s.Layer == LayerFlag:
+		return "flag --" + s.Name
+	}
+	return s.Layer.String() + " " + s.Name
+}
+
+// ConfigLoader fills a struct from, in increasing order of precedence,
+// `default` tags, Files, environment variables and flags, remembering which
+// sources set every field.
+//
+//	loader := &ConfigLoader{Files: []string{"/etc/app.yaml"}, EnvPrefix: "APP", FlagSet: fs}
+//	explain := fs.String("explain", "", "show where a setting comes from")
+//	if err := loader.Load(&cfg); err != nil { ... }
+//	if *explain != "" {
+//		fmt.Println(loader.Explain(*explain))
+//	}
+type ConfigLoader struct {
+	// Files are read in order with ReadConfigFile.
+	Files []string
+	// EnvPrefix is passed to LoadEnv; variables are only read if it is set.
+	EnvPrefix string
+	// Environ replaces the process environment if non-nil.
+	Environ []string
+	// FlagSet, if set, gets a flag bound for every field as by BindFlags,
+	// prefixed with FlagPrefix, and is parsed from Args (os.Args[1:] if nil).
+	FlagSet    *flag.FlagSet
+	FlagPrefix string
+	Args       []string
+
+	sources []pathSource
+	// flags are bound to FlagSet by the first Load and reused after
+	flagType  reflect.Type
+	flagNames map[string]string
+	target    interface{}
+	pending   []pendingFlag
+}
+
+type pathSource struct {
+	segs   []string
+	source ConfigSource
+}
+
+type pendingFlag struct {
+	path, name, value string
+}
+
+// Load fills obj, which must be a pointer to a struct. Files and defaults are
+// only recorded as the source of values they change. Variables carrying
+// EnvPrefix that match no field are returned as an *UnknownEnvError once
+// every layer has been applied. Load can be called again to reload, with a
+// pointer to the same type, as the flags stay bound to FlagSet.
+func (l *ConfigLoader) Load(obj interface{}) error {
+	l.sources = nil
+
+	// defaults first, so that flags are bound with them as their defaults
+	if err := l.recordChanges(obj, ConfigSource{Layer: LayerDefault}, func() error {
+		return ApplyDefaults(obj)
+	}); err != nil {
+		return err
+	}
+
+	if l.FlagSet != nil {
+		if err := l.bindFlagSet(obj); err != nil {
+			return err
+		}
+		l.target, l.pending = obj, nil
+		args := l.Args
+		if args == nil {
+			args = os.Args[1:]
+		}
+		if err := l.FlagSet.Parse(args); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range l.Files {
+		if err := l.recordChanges(obj, ConfigSource{LayerFile, file}, func() error {
+			return ReadConfigFile(file, obj)
+		}); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	var unknownEnv error
+	if l.EnvPrefix != "" {
+		environ := l.Environ
+		if environ == nil {
+			environ = os.Environ()
+		}
+		bindings, err := loadEnv(l.EnvPrefix, environ, obj)
+		for _, b := range bindings {
+			l.record(b.Path, ConfigSource{LayerEnv, b.Name})
+		}
+		if _, ok := err.(*UnknownEnvError); ok {
+			unknownEnv = err
+		} else if err != nil {
+			return err
+		}
+	}
+
+	for _, f := range l.pending {
+		if err := DefaultStringCoercer.SetPath(obj, f.path, f.value); err != nil {
+			return fmt.Errorf("flag --%s: %v", f.name, err)
+		}
+		l.record(f.path, ConfigSource{LayerFlag, f.name})
+	}
+	return unknownEnv
+}
+
+// bindFlagSet binds a flag for every field of obj to FlagSet, unless an
+// earlier Load already did.
+func (l *ConfigLoader) bindFlagSet(obj interface{}) error {
+	if t := reflect.TypeOf(obj); l.flagType != nil {
+		if t != l.flagType {
+			return fmt.Errorf("flags are bound to %s, cannot load %s", l.flagType, t)
+		}
+		return nil
+	}
+
+	names, err := bindFlags(l.FlagSet, obj, l.FlagPrefix, func(path, s string) error {
+		// check the value against a copy now, so that the flag package
+		// reports it, but only apply it once files and the environment
+		// have been read
+		scratch := deepCopyValue(reflect.ValueOf(l.target).Elem())
+		if err := DefaultStringCoercer.SetPath(scratch.Addr().Interface(), path, s); err != nil {
+			return err
+		}
+		l.pending = append(l.pending, pendingFlag{path: path, name: l.flagNames[path], value: s})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	l.flagType = reflect.TypeOf(obj)
+	l.flagNames = map[string]string{}
+	for name, path := range names {
+		l.flagNames[path] = name
+	}
+	return nil
+}
+
+// recordChanges runs fn, recording source for every leaf of obj it changes.
+func (l *ConfigLoader) recordChanges(obj interface{}, source ConfigSource, fn func() error) error {
+	before := Flatten(obj)
+	if err := fn(); err != nil {
+		return err
+	}
+	after := Flatten(obj)
+	changed := map[string]interface{}{}
+	for path, v := range after {
+		if old, ok := before[path]; !ok || !reflect.DeepEqual(old, v) {
+			changed[path] = v
+		}
+	}
+	for path, v := range before {
+		if _, ok := after[path]; !ok {
+			changed[path] = v
+		}
+	}
+	for _, path := range sortedGenericKeys(changed) {
+		l.record(path, source)
+	}
+	return nil
+}
+
+func (l *ConfigLoader) record(path string, source ConfigSource) {
+	segs, _ := splitPath(path)
+	l.sources = append(l.sources, pathSource{segs, source})
+}
+
+// Sources returns every source that set path, or a value within or around
+// it, during the last Load, in order of precedence, lowest first.
+func (l *ConfigLoader) Sources(path string) ([]ConfigSource, error) {
+	segs, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	var sources []ConfigSource
+	for _, s := range l.sources {
+		if !pathOverlaps(segs, s.segs) {
+			continue
+		}
+		if n := len(sources); n == 0 || sources[n-1] != s.source {
+			sources = append(sources, s.source)
+		}
+	}
+	return sources, nil
+}
+
+// Explain describes where the value at path came from, e.g. "set by env
+// APP_SERVER_PORT, overriding file /etc/app.yaml and default".
+func (l *ConfigLoader) Explain(path string) string {
+	sources, err := l.Sources(path)
+	if err != nil {
+		return err.Error()
+	}
+	if len(sources) == 0 {
+		return "not set by any source"
+	}
+	last := len(sources) - 1
+	msg := "set by " + sources[last].String()
+	if last > 0 {
+		overridden := make([]string, last)
+		for i := range overridden {
+			overridden[i] = sources[last-1-i].String()
+		}
+		msg += ", overriding " + strings.Join(overridden[:last-1], ", ")
+		if last > 1 {
+			msg += " and "
+		}
+		msg += overridden[last-1]
+	}
+	return msg
+}
+
+// pathOverlaps reports whether one of the paths a and b contains the other.
+func pathOverlaps(a, b []string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return isPathPrefix(a, b)
+}
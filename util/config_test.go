@@ -0,0 +1,105 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type loaderServer struct {
+	Host string `default:"localhost" json:"host"`
+	Port int    `default:"80" json:"port"`
+}
+
+type loaderConfig struct {
+	Name   string       `json:"name"`
+	Server loaderServer `json:"server"`
+	Tags   []string     `json:"tags"`
+	Debug  bool         `json:"debug"`
+}
+
+func TestConfigLoader(t *testing.T) {
+	Convey("With config files, variables and flags", t, func() {
+		dir, err := ioutil.TempDir("", "config")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		jsonFile := filepath.Join(dir, "base.json")
+		So(ioutil.WriteFile(jsonFile, []byte(`{"name": "base", "server": {"port": 81}, "tags": ["a", "b"]}`), 0644), ShouldBeNil)
+		yamlFile := filepath.Join(dir, "local.yaml")
+		So(ioutil.WriteFile(yamlFile, []byte("name: local\n"), 0644), ShouldBeNil)
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		l := &ConfigLoader{
+			Files:     []string{jsonFile, yamlFile},
+			EnvPrefix: "APP",
+			Environ:   []string{"APP_SERVER_PORT=82", "APP_TAGS=c"},
+			FlagSet:   fs,
+			Args:      []string{"-debug", "-server.host=h"},
+		}
+		var cfg loaderConfig
+		So(l.Load(&cfg), ShouldBeNil)
+
+		Convey("Later layers should take precedence", func() {
+			So(cfg, ShouldResemble, loaderConfig{
+				Name:   "local",
+				Server: loaderServer{Host: "h", Port: 82},
+				Tags:   []string{"c"},
+				Debug:  true,
+			})
+		})
+		Convey("Sources should list every layer that set a value, lowest first", func() {
+			sources, err := l.Sources("Server.Port")
+			So(err, ShouldBeNil)
+			So(sources, ShouldResemble, []ConfigSource{
+				{Layer: LayerDefault}, {LayerFile, jsonFile}, {LayerEnv, "APP_SERVER_PORT"},
+			})
+			sources, err = l.Sources("Server")
+			So(err, ShouldBeNil)
+			So(sources[len(sources)-1], ShouldResemble, ConfigSource{LayerFlag, "server.host"})
+		})
+		Convey("Explain should describe the winning and overridden sources", func() {
+			So(l.Explain("Server.Port"), ShouldEqual, "set by env APP_SERVER_PORT, overriding file "+jsonFile+" and default")
+			So(l.Explain("Server.Host"), ShouldEqual, "set by flag --server.host, overriding default")
+			So(l.Explain("Name"), ShouldEqual, "set by file "+yamlFile+", overriding file "+jsonFile)
+			So(l.Explain("Debug"), ShouldEqual, "set by flag --debug")
+		})
+		Convey("Flags should show the default tag values as their defaults", func() {
+			So(fs.Lookup("server.port").DefValue, ShouldEqual, "80")
+			So(fs.Lookup("server.host").DefValue, ShouldEqual, "localhost")
+		})
+		Convey("Loading again should reuse the bound flags", func() {
+			var again loaderConfig
+			So(l.Load(&again), ShouldBeNil)
+			So(again, ShouldResemble, cfg)
+			So(l.Load(&struct{ Other int }{}), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Unknown variables should be reported after every layer is applied", t, func() {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		l := &ConfigLoader{
+			EnvPrefix: "APP",
+			Environ:   []string{"APP_SERVER_PORT=1", "APP_EXTRA=2"},
+			FlagSet:   fs,
+			Args:      []string{"-server.host", "h"},
+		}
+		var cfg loaderConfig
+		err := l.Load(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.(*UnknownEnvError).Names, ShouldResemble, []string{"APP_EXTRA"})
+		So(cfg.Server, ShouldResemble, loaderServer{Host: "h", Port: 1})
+	})
+
+	Convey("Invalid flags and unreadable files should fail loading", t, func() {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		var cfg loaderConfig
+		So((&ConfigLoader{FlagSet: fs, Args: []string{"-server.port=x"}}).Load(&cfg), ShouldNotBeNil)
+		So((&ConfigLoader{Files: []string{"missing.toml"}}).Load(&cfg), ShouldNotBeNil)
+	})
+}
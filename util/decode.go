@@ -0,0 +1,574 @@
+package util
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+// DecodeOptions configure Decode and Encode.
+type DecodeOptions struct {
+	// TagName is the struct tag naming fields, "json" if empty. Tags are
+	// interpreted the way encoding/json does, plus the "squash" option, which
+	// inlines the fields of a struct field, and the "remain" option, which
+	// marks a map field that receives every key no other field matched.
+	TagName string
+	// WeaklyTyped converts between strings, numbers and bools, e.g. "1" to
+	// an int or true to "true", and decodes single values into slices.
+	WeaklyTyped bool
+	// ErrorUnused fails decoding on map keys that match no field.
+	ErrorUnused bool
+}
+
+func (o DecodeOptions) tagName() string {
+	if o.TagName == "" {
+		return "json"
+	}
+	return o.TagName
+}
+
+// Decode decodes generic data, such as the maps and slices produced by
+// unmarshaling JSON or YAML into an interface{}, into out, which must be a
+// pointer. Map keys are matched to struct fields by tag name, falling back to
+// a case-insensitive match. Numbers are converted between types as long as
+// they fit, durations and times may be given as strings, and types
+// implementing encoding.TextUnmarshaler are decoded from strings. Fields
+// whose keys are absent keep their value.
+func Decode(input interface{}, out interface{}, opts DecodeOptions) error {
+	root, err := settableRoot(out)
+	if err != nil {
+		return err
+	}
+	d := &mapDecoder{opts: opts, tag: opts.tagName()}
+	return d.decode("", reflect.ValueOf(input), root)
+}
+
+type mapDecoder struct {
+	opts DecodeOptions
+	tag  string
+}
+
+func (d *mapDecoder) errorf(path string, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if path == "" {
+		return fmt.Errorf("%s", msg)
+	}
+	return fmt.Errorf("%s: %s", path, msg)
+}
+
+func (d *mapDecoder) decode(path string, in, out reflect.Value) error {
+	for in.IsValid() && (in.Kind() == reflect.Interface || in.Kind() == reflect.Ptr) {
+		if in.Type().AssignableTo(out.Type()) {
+			break
+		}
+		in = in.Elem()
+	}
+	if !in.IsValid() {
+		out.Set(reflect.Zero(out.Type()))
+		return nil
+	}
+	if in.Type().AssignableTo(out.Type()) {
+		out.Set(deepCopyValue(in))
+		return nil
+	}
+
+	t := out.Type()
+	switch t {
+	case durationType:
+		return d.decodeDuration(path, in, out)
+	case timeType:
+		if in.Kind() != reflect.String {
+			return d.errorf(path, "cannot decode %s into %s", in.Type(), t)
+		}
+		val, err := DefaultStringCoercer.Coerce(in.String(), t)
+		if err != nil {
+			return d.errorf(path, "%v", err)
+		}
+		out.Set(val)
+		return nil
+	}
+	if in.Kind() == reflect.String && reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		v := reflect.New(t)
+		if err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(in.String())); err != nil {
+			return d.errorf(path, "%v", err)
+		}
+		out.Set(v.Elem())
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		if out.IsNil() {
+			out.Set(reflect.New(t.Elem()))
+		}
+		return d.decode(path, in, out.Elem())
+	case reflect.Struct:
+		if in.Kind() == reflect.Struct {
+			encoded, err := encodeValue(in, d.tag)
+			if err != nil {
+				return d.errorf(path, "%v", err)
+			}
+			in = reflect.ValueOf(encoded)
+		}
+		if in.Kind() != reflect.Map {
+			return d.errorf(path, "cannot decode %s into %s", in.Type(), t)
+		}
+		return d.decodeStruct(path, in, out)
+	case reflect.Map:
+		if in.Kind() == reflect.Struct {
+			encoded, err := encodeValue(in, d.tag)
+			if err != nil {
+				return d.errorf(path, "%v", err)
+			}
+			in = reflect.ValueOf(encoded)
+		}
+		if in.Kind() != reflect.Map {
+			return d.errorf(path, "cannot decode %s into %s", in.Type(), t)
+		}
+		return d.decodeMap(path, in, out)
+	case reflect.Slice, reflect.Array:
+		return d.decodeSlice(path, in, out)
+	case reflect.Interface:
+		if !in.Type().Implements(t) {
+			return d.errorf(path, "%s does not implement %s", in.Type(), t)
+		}
+		out.Set(deepCopyValue(in))
+		return nil
+	}
+	return d.decodeScalar(path, in, out)
+}
+
+func (d *mapDecoder) decodeStruct(path string, in, out reflect.Value) error {
+	fields := taggedFields(out.Type(), d.tag)
+	var remain *taggedField
+	for i := range fields {
+		if fields[i].hasOption("remain") {
+			remain = &fields[i]
+		}
+	}
+
+	keys := in.MapKeys()
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = fmt.Sprint(key.Interface())
+	}
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return names[order[a]] < names[order[b]] })
+
+	var unused []string
+	remaining := map[string]interface{}{}
+	for _, i := range order {
+		name := names[i]
+		field, ok := matchTaggedField(fields, name)
+		if !ok || field == remain {
+			if remain != nil {
+				remaining[name] = interfaceOrNil(in.MapIndex(keys[i]))
+			} else {
+				unused = append(unused, name)
+			}
+			continue
+		}
+		fieldPath := joinField(path, field.Field.Name)
+		if err := d.decode(fieldPath, in.MapIndex(keys[i]), fieldByIndexAlloc(out, field.Index)); err != nil {
+			return err
+		}
+	}
+
+	if remain != nil && len(remaining) > 0 {
+		fv := fieldByIndexAlloc(out, remain.Index)
+		if err := d.decode(joinField(path, remain.Field.Name), reflect.ValueOf(remaining), fv); err != nil {
+			return err
+		}
+	}
+	if d.opts.ErrorUnused && len(unused) > 0 {
+		return d.errorf(path, "unknown keys %s", strings.Join(unused, ", "))
+	}
+	return nil
+}
+
+// matchTaggedField finds the field named name, falling back to a
+// case-insensitive match.
+func matchTaggedField(fields []taggedField, name string) (*taggedField, bool) {
+	for i := range fields {
+		if fields[i].Name == name {
+			return &fields[i], true
+		}
+	}
+	for i := range fields {
+		if strings.EqualFold(fields[i].Name, name) {
+			return &fields[i], true
+		}
+	}
+	return nil, false
+}
+
+func (d *mapDecoder) decodeMap(path string, in, out reflect.Value) error {
+	t := out.Type()
+	if out.IsNil() {
+		out.Set(reflect.MakeMapWithSize(t, in.Len()))
+	}
+	for _, key := range sortedMapKeys(in) {
+		keyPath := joinKey(path, key.Interface())
+		k := reflect.New(t.Key()).Elem()
+		if err := d.decode(keyPath, key, k); err != nil {
+			return err
+		}
+		elem := reflect.New(t.Elem()).Elem()
+		if cur := out.MapIndex(k); cur.IsValid() {
+			elem.Set(cur)
+		}
+		if err := d.decode(keyPath, in.MapIndex(key), elem); err != nil {
+			return err
+		}
+		out.SetMapIndex(k, elem)
+	}
+	return nil
+}
+
+func (d *mapDecoder) decodeSlice(path string, in, out reflect.Value) error {
+	t := out.Type()
+	if in.Kind() == reflect.String && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		out.SetBytes([]byte(in.String()))
+		return nil
+	}
+	if in.Kind() != reflect.Slice && in.Kind() != reflect.Array {
+		if !d.opts.WeaklyTyped {
+			return d.errorf(path, "cannot decode %s into %s", in.Type(), t)
+		}
+		single := reflect.MakeSlice(reflect.SliceOf(in.Type()), 1, 1)
+		single.Index(0).Set(in)
+		in = single
+	}
+
+	n := in.Len()
+	if t.Kind() == reflect.Array {
+		if n > t.Len() {
+			return d.errorf(path, "cannot decode %d elements into %s", n, t)
+		}
+		out.Set(reflect.Zero(t))
+	} else {
+		out.Set(reflect.MakeSlice(t, n, n))
+	}
+	for i := 0; i < n; i++ {
+		if err := d.decode(joinIndex(path, i), in.Index(i), out.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *mapDecoder) decodeDuration(path string, in, out reflect.Value) error {
+	if in.Kind() != reflect.String || in.Type() == jsonNumberType {
+		// plain numbers are nanoseconds
+		return d.decodeScalar(path, in, out)
+	}
+	dur, err := time.ParseDuration(strings.TrimSpace(in.String()))
+	if err != nil {
+		return d.errorf(path, "%v", err)
+	}
+	out.SetInt(int64(dur))
+	return nil
+}
+
+func (d *mapDecoder) decodeScalar(path string, in, out reflect.Value) error {
+	t := out.Type()
+	if in.Type() == jsonNumberType {
+		return d.decodeNumberString(path, in.String(), out)
+	}
+
+	inKind := in.Kind()
+	switch {
+	case inKind == t.Kind() && (inKind == reflect.String || inKind == reflect.Bool):
+		out.Set(in.Convert(t))
+		return nil
+	case isNumberKind(inKind) && isNumberKind(t.Kind()):
+		if !setNumber(in, out) {
+			return d.errorf(path, "%v does not fit in %s", in.Interface(), t)
+		}
+		return nil
+	case !d.opts.WeaklyTyped:
+		return d.errorf(path, "cannot decode %s into %s", in.Type(), t)
+	}
+
+	switch {
+	case inKind == reflect.String:
+		if isNumberKind(t.Kind()) {
+			return d.decodeNumberString(path, in.String(), out)
+		}
+		val, err := DefaultStringCoercer.Coerce(in.String(), t)
+		if err != nil {
+			return d.errorf(path, "%v", err)
+		}
+		out.Set(val)
+		return nil
+	case t.Kind() == reflect.String:
+		switch {
+		case inKind == reflect.Bool, isNumberKind(inKind):
+			out.SetString(fmt.Sprint(in.Interface()))
+			return nil
+		}
+	case inKind == reflect.Bool && isNumberKind(t.Kind()):
+		n := int64(0)
+		if in.Bool() {
+			n = 1
+		}
+		setNumber(reflect.ValueOf(n), out)
+		return nil
+	case isNumberKind(inKind) && t.Kind() == reflect.Bool:
+		zero := reflect.Zero(in.Type()).Interface()
+		out.SetBool(in.Interface() != zero)
+		return nil
+	}
+	return d.errorf(path, "cannot decode %s into %s", in.Type(), t)
+}
+
+// decodeNumberString parses s, such as a json.Number, into the number out.
+func (d *mapDecoder) decodeNumberString(path string, s string, out reflect.Value) error {
+	s = strings.TrimSpace(s)
+	var n reflect.Value
+	if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+		n = reflect.ValueOf(i)
+	} else if u, err := strconv.ParseUint(s, 0, 64); err == nil {
+		n = reflect.ValueOf(u)
+	} else if f, err := strconv.ParseFloat(s, 64); err == nil {
+		n = reflect.ValueOf(f)
+	} else {
+		return d.errorf(path, "cannot parse %q as %s", s, out.Type())
+	}
+	if out.Kind() == reflect.String {
+		out.SetString(s)
+		return nil
+	}
+	if !isNumberKind(out.Kind()) {
+		return d.errorf(path, "cannot decode number into %s", out.Type())
+	}
+	if !setNumber(n, out) {
+		return d.errorf(path, "%s does not fit in %s", s, out.Type())
+	}
+	return nil
+}
+
+// setNumber stores the number in into the number out if it fits exactly.
+func setNumber(in, out reflect.Value) bool {
+	switch {
+	case isIntKind(out.Kind()):
+		var n int64
+		switch {
+		case isIntKind(in.Kind()):
+			n = in.Int()
+		case isUintKind(in.Kind()):
+			if in.Uint() > math.MaxInt64 {
+				return false
+			}
+			n = int64(in.Uint())
+		default:
+			f := in.Float()
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+				return false
+			}
+			n = int64(f)
+		}
+		if out.OverflowInt(n) {
+			return false
+		}
+		out.SetInt(n)
+	case isUintKind(out.Kind()):
+		var n uint64
+		switch {
+		case isIntKind(in.Kind()):
+			if in.Int() < 0 {
+				return false
+			}
+			n = uint64(in.Int())
+		case isUintKind(in.Kind()):
+			n = in.Uint()
+		default:
+			f := in.Float()
+			if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+				return false
+			}
+			n = uint64(f)
+		}
+		if out.OverflowUint(n) {
+			return false
+		}
+		out.SetUint(n)
+	default:
+		var f float64
+		switch {
+		case isIntKind(in.Kind()):
+			f = float64(in.Int())
+		case isUintKind(in.Kind()):
+			f = float64(in.Uint())
+		default:
+			f = in.Float()
+		}
+		if out.OverflowFloat(f) {
+			return false
+		}
+		out.SetFloat(f)
+	}
+	return true
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	return isIntKind(k) || isUintKind(k) || isFloatKind(k)
+}
+
+// Encode is the reverse of Decode: it converts the struct obj into a
+// map[string]interface{} keyed by the field names in opts.TagName tags.
+// Nested structs become maps, slices and arrays become []interface{}, and
+// maps become map[string]interface{}. Durations and other types
+// implementing encoding.TextMarshaler become strings, except for
+// time.Time, which is kept as is. Fields tagged "omitempty" are left out if
+// empty and "remain" maps are merged into the result.
+func Encode(obj interface{}, opts DecodeOptions) (map[string]interface{}, error) {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot encode %T, it is not a struct", obj)
+	}
+	if !v.CanAddr() {
+		// so that fields can use MarshalText methods with pointer receivers
+		addressable := reflect.New(v.Type()).Elem()
+		addressable.Set(v)
+		v = addressable
+	}
+	encoded, err := encodeValue(v, opts.tagName())
+	if err != nil {
+		return nil, err
+	}
+	return encoded.(map[string]interface{}), nil
+}
+
+var basicKindTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:    reflect.TypeOf(false),
+	reflect.String:  reflect.TypeOf(""),
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Uintptr: reflect.TypeOf(uintptr(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+}
+
+func encodeValue(v reflect.Value, tag string) (interface{}, error) {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	switch t := v.Type(); {
+	case t == timeType:
+		return v.Interface(), nil
+	case t == durationType:
+		return v.Interface().(time.Duration).String(), nil
+	case t.Implements(textMarshalerType):
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	case v.CanAddr() && reflect.PtrTo(t).Implements(textMarshalerType):
+		text, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		m := map[string]interface{}{}
+		var remain reflect.Value
+		for _, field := range taggedFields(v.Type(), tag) {
+			fv, err := v.FieldByIndexErr(field.Index)
+			if err != nil {
+				// a field promoted through a nil embedded pointer
+				continue
+			}
+			if field.hasOption("remain") {
+				remain = fv
+				continue
+			}
+			if field.hasOption("omitempty") && isEmptyValue(fv) {
+				continue
+			}
+			encoded, err := encodeValue(fv, tag)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", field.Name, err)
+			}
+			m[field.Name] = encoded
+		}
+		if remain.IsValid() && remain.Kind() == reflect.Map {
+			for _, key := range sortedMapKeys(remain) {
+				name := DefaultStringCoercer.Format(key)
+				if _, ok := m[name]; ok {
+					continue
+				}
+				encoded, err := encodeValue(remain.MapIndex(key), tag)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", name, err)
+				}
+				m[name] = encoded
+			}
+		}
+		return m, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		for _, key := range sortedMapKeys(v) {
+			name := DefaultStringCoercer.Format(key)
+			encoded, err := encodeValue(v.MapIndex(key), tag)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			m[name] = encoded
+		}
+		return m, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return nil, nil
+			}
+			if v.Type().Elem().Kind() == reflect.Uint8 {
+				return string(v.Bytes()), nil
+			}
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			encoded, err := encodeValue(v.Index(i), tag)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %v", i, err)
+			}
+			s[i] = encoded
+		}
+		return s, nil
+	}
+
+	if basic, ok := basicKindTypes[v.Kind()]; ok {
+		return v.Convert(basic).Interface(), nil
+	}
+	return nil, fmt.Errorf("cannot encode %s", v.Type())
+}
@@ -0,0 +1,213 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type decodeBase struct {
+	ID      int    `json:"id"`
+	Created string `json:"created,omitempty"`
+}
+
+type decodeDatabase struct {
+	Host string `json:"host"`
+	Port uint16 `json:"port"`
+}
+
+type decodePlugin struct {
+	decodeBase
+	Name     string                 `json:"name"`
+	Enabled  bool                   `json:"enabled"`
+	Ratio    float64                `json:"ratio"`
+	Timeout  time.Duration          `json:"timeout"`
+	IP       net.IP                 `json:"ip"`
+	Tags     []string               `json:"tags"`
+	DB       *decodeDatabase        `json:"db"`
+	Replicas []decodeDatabase       `json:"replicas"`
+	Limits   map[string]int         `json:"limits"`
+	Extra    map[string]interface{} `json:",remain"`
+}
+
+// decodeLevel has text methods with pointer receivers only.
+type decodeLevel struct {
+	Name string
+}
+
+func (l *decodeLevel) MarshalText() ([]byte, error) {
+	return []byte("level-" + l.Name), nil
+}
+
+func (l *decodeLevel) UnmarshalText(text []byte) error {
+	l.Name = strings.TrimPrefix(string(text), "level-")
+	return nil
+}
+
+type decodeSquashed struct {
+	Base decodeBase `json:",squash"`
+	Name string     `json:"name"`
+}
+
+func TestDecode(t *testing.T) {
+	Convey("When decoding generic data into a struct", t, func() {
+		input := map[string]interface{}{
+			"id":      1,
+			"name":    "auth",
+			"Enabled": true,
+			"ratio":   1,
+			"timeout": "5s",
+			"ip":      "10.0.0.1",
+			"tags":    []interface{}{"a", "b"},
+			"db":      map[interface{}]interface{}{"host": "db", "port": 5432.0},
+			"replicas": []interface{}{
+				map[string]interface{}{"host": "r1"},
+			},
+			"limits": map[string]interface{}{"cpu": json.Number("2")},
+			"custom": "x",
+		}
+
+		Convey("Fields should be matched by tag, embedded structs inlined and unknown keys kept", func() {
+			var p decodePlugin
+			So(Decode(input, &p, DecodeOptions{}), ShouldBeNil)
+			So(p.ID, ShouldEqual, 1)
+			So(p.Name, ShouldEqual, "auth")
+			So(p.Enabled, ShouldBeTrue)
+			So(p.Ratio, ShouldEqual, 1.0)
+			So(p.Timeout, ShouldEqual, 5*time.Second)
+			So(p.IP.String(), ShouldEqual, "10.0.0.1")
+			So(p.Tags, ShouldResemble, []string{"a", "b"})
+			So(p.DB, ShouldResemble, &decodeDatabase{Host: "db", Port: 5432})
+			So(p.Replicas, ShouldResemble, []decodeDatabase{{Host: "r1"}})
+			So(p.Limits, ShouldResemble, map[string]int{"cpu": 2})
+			So(p.Extra, ShouldResemble, map[string]interface{}{"custom": "x"})
+		})
+
+		Convey("Fields whose keys are absent should keep their value", func() {
+			p := decodePlugin{Name: "old", Ratio: 0.5}
+			So(Decode(map[string]interface{}{"name": "new"}, &p, DecodeOptions{}), ShouldBeNil)
+			So(p.Name, ShouldEqual, "new")
+			So(p.Ratio, ShouldEqual, 0.5)
+		})
+
+		Convey("Unknown keys should fail with ErrorUnused unless a remain field catches them", func() {
+			var db decodeDatabase
+			err := Decode(map[string]interface{}{"host": "h", "user": "u"}, &db, DecodeOptions{ErrorUnused: true})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "user")
+
+			var p decodePlugin
+			So(Decode(input, &p, DecodeOptions{ErrorUnused: true}), ShouldBeNil)
+		})
+
+		Convey("Mismatched types should fail with the path of the field", func() {
+			var p decodePlugin
+			err := Decode(map[string]interface{}{"db": map[string]interface{}{"port": "5432"}}, &p, DecodeOptions{})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "DB.Port:")
+
+			err = Decode(map[string]interface{}{"db": map[string]interface{}{"port": 70000}}, &p, DecodeOptions{})
+			So(err, ShouldNotBeNil)
+			err = Decode(map[string]interface{}{"id": 1.5}, &p, DecodeOptions{})
+			So(err, ShouldNotBeNil)
+			err = Decode(map[string]interface{}{"tags": "a"}, &p, DecodeOptions{})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Weak typing should convert strings, numbers and bools", func() {
+			var p decodePlugin
+			err := Decode(map[string]interface{}{
+				"id":      "7",
+				"name":    12,
+				"enabled": "true",
+				"ratio":   "0.25",
+				"tags":    "single",
+				"db":      map[string]interface{}{"port": "5432"},
+			}, &p, DecodeOptions{WeaklyTyped: true})
+			So(err, ShouldBeNil)
+			So(p.ID, ShouldEqual, 7)
+			So(p.Name, ShouldEqual, "12")
+			So(p.Enabled, ShouldBeTrue)
+			So(p.Ratio, ShouldEqual, 0.25)
+			So(p.Tags, ShouldResemble, []string{"single"})
+			So(p.DB.Port, ShouldEqual, 5432)
+		})
+
+		Convey("Squashed fields should be inlined", func() {
+			var s decodeSquashed
+			So(Decode(map[string]interface{}{"id": 3, "name": "n"}, &s, DecodeOptions{}), ShouldBeNil)
+			So(s.Base.ID, ShouldEqual, 3)
+			So(s.Name, ShouldEqual, "n")
+		})
+
+		Convey("Other tags should be usable", func() {
+			var out struct {
+				Port int `yaml:"listen_port"`
+			}
+			So(Decode(map[string]interface{}{"listen_port": 80}, &out, DecodeOptions{TagName: "yaml"}), ShouldBeNil)
+			So(out.Port, ShouldEqual, 80)
+		})
+	})
+}
+
+func TestEncode(t *testing.T) {
+	Convey("When encoding a struct into a map", t, func() {
+		p := decodePlugin{
+			decodeBase: decodeBase{ID: 1},
+			Name:       "auth",
+			Timeout:    time.Minute,
+			IP:         net.ParseIP("10.0.0.1"),
+			Tags:       []string{"a"},
+			DB:         &decodeDatabase{Host: "db", Port: 1},
+			Limits:     map[string]int{"cpu": 2},
+			Extra:      map[string]interface{}{"custom": "x"},
+		}
+		m, err := Encode(&p, DecodeOptions{})
+		So(err, ShouldBeNil)
+
+		Convey("Fields should be keyed by tag and nested values converted", func() {
+			So(m, ShouldResemble, map[string]interface{}{
+				"id":       1,
+				"name":     "auth",
+				"enabled":  false,
+				"ratio":    0.0,
+				"timeout":  "1m0s",
+				"ip":       "10.0.0.1",
+				"tags":     []interface{}{"a"},
+				"db":       map[string]interface{}{"host": "db", "port": uint16(1)},
+				"replicas": nil,
+				"limits":   map[string]interface{}{"cpu": 2},
+				"custom":   "x",
+			})
+		})
+
+		Convey("Decoding the result should give back the struct", func() {
+			var back decodePlugin
+			So(Decode(m, &back, DecodeOptions{}), ShouldBeNil)
+			So(back, ShouldResemble, p)
+		})
+
+		Convey("Pointer receiver MarshalText methods should be used", func() {
+			type logging struct {
+				Level decodeLevel `json:"level"`
+			}
+			for _, obj := range []interface{}{&logging{decodeLevel{"debug"}}, logging{decodeLevel{"debug"}}} {
+				m, err := Encode(obj, DecodeOptions{})
+				So(err, ShouldBeNil)
+				So(m, ShouldResemble, map[string]interface{}{"level": "level-debug"})
+				var back logging
+				So(Decode(m, &back, DecodeOptions{}), ShouldBeNil)
+				So(back.Level.Name, ShouldEqual, "debug")
+			}
+		})
+
+		Convey("Only structs should be encodable", func() {
+			_, err := Encode(3, DecodeOptions{})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,122 @@
+package util
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ApplyDefaults sets every zero-valued field of obj that has a
+// `default:"..."` tag, parsing the tag with DefaultStringCoercer, so slices
+// ("a,b"), maps ("k=v"), durations ("30s") and times are supported. It
+// recurses into nested structs, slices and maps of structs, and nil pointers
+// to structs, which are allocated (like SetNestedStructIndex does) only if a
+// default is set inside them. obj must be a pointer.
+func ApplyDefaults(obj interface{}) error {
+	root, err := settableRoot(obj)
+	if err != nil {
+		return err
+	}
+	d := &defaulter{allocating: map[reflect.Type]bool{}, guard: newCycleGuard()}
+	_, err = d.apply("", root)
+	return err
+}
+
+type defaulter struct {
+	// types being allocated on the current descent, so that recursive types
+	// such as linked lists are not allocated forever
+	allocating map[reflect.Type]bool
+	guard      cycleGuard
+}
+
+// apply sets defaults within v and reports whether it changed anything.
+func (d *defaulter) apply(path string, v reflect.Value) (bool, error) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			elem, leave, ok := d.guard.enter(v)
+			if !ok {
+				return false, nil
+			}
+			defer leave()
+			return d.apply(path, elem)
+		}
+		t := v.Type().Elem()
+		if t.Kind() != reflect.Struct || d.allocating[t] {
+			return false, nil
+		}
+		d.allocating[t] = true
+		defer delete(d.allocating, t)
+
+		elem := reflect.New(t)
+		changed, err := d.apply(path, elem.Elem())
+		if changed {
+			v.Set(elem)
+		}
+		return changed, err
+	case reflect.Struct:
+		changed := false
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			fieldPath := joinField(path, field.Name)
+			fv := v.Field(i)
+
+			if tag, ok := field.Tag.Lookup("default"); ok && fv.IsZero() {
+				val, err := DefaultStringCoercer.Coerce(tag, field.Type)
+				if err != nil {
+					return changed, fmt.Errorf("invalid default for %s: %v", fieldPath, err)
+				}
+				fv.Set(val)
+				changed = true
+				continue
+			}
+
+			c, err := d.apply(fieldPath, fv)
+			changed = changed || c
+			if err != nil {
+				return changed, err
+			}
+		}
+		return changed, nil
+	case reflect.Slice, reflect.Array:
+		changed := false
+		for i := 0; i < v.Len(); i++ {
+			c, err := d.apply(joinIndex(path, i), v.Index(i))
+			changed = changed || c
+			if err != nil {
+				return changed, err
+			}
+		}
+		return changed, nil
+	case reflect.Map:
+		changed := false
+		for _, key := range sortedMapKeys(v) {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			c, err := d.apply(joinKey(path, key.Interface()), elem)
+			if c {
+				v.SetMapIndex(key, elem)
+				changed = true
+			}
+			if err != nil {
+				return changed, err
+			}
+		}
+		return changed, nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return false, nil
+		}
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		c, err := d.apply(path, elem)
+		if c {
+			v.Set(elem)
+		}
+		return c, err
+	}
+	return false, nil
+}
@@ -0,0 +1,73 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"testing"
+	"time"
+)
+
+type defaultsServer struct {
+	Host    string         `default:"localhost"`
+	Port    int            `default:"8080"`
+	Timeout time.Duration  `default:"30s"`
+	Tags    []string       `default:"a,b"`
+	Limits  map[string]int `default:"cpu=2"`
+	Retries *int           `default:"3"`
+}
+
+type defaultsList struct {
+	Name string `default:"node"`
+	Next *defaultsList
+}
+
+type defaultsConfig struct {
+	Server  defaultsServer
+	Backup  *defaultsServer
+	Plain   *struct{ X int }
+	Servers []defaultsServer
+	ByName  map[string]defaultsServer
+	List    *defaultsList
+}
+
+func TestApplyDefaults(t *testing.T) {
+	Convey("When applying defaults to a struct", t, func() {
+		c := &defaultsConfig{
+			Server:  defaultsServer{Port: 1, Tags: []string{"x"}},
+			Servers: []defaultsServer{{Host: "h"}},
+			ByName:  map[string]defaultsServer{"a": {}},
+		}
+		So(ApplyDefaults(c), ShouldBeNil)
+
+		Convey("Zero fields should be parsed from their tags", func() {
+			So(c.Server.Host, ShouldEqual, "localhost")
+			So(c.Server.Timeout, ShouldEqual, 30*time.Second)
+			So(c.Server.Limits, ShouldResemble, map[string]int{"cpu": 2})
+			So(*c.Server.Retries, ShouldEqual, 3)
+		})
+		Convey("Non-zero fields should be left untouched", func() {
+			So(c.Server.Port, ShouldEqual, 1)
+			So(c.Server.Tags, ShouldResemble, []string{"x"})
+			So(c.Servers[0].Host, ShouldEqual, "h")
+		})
+		Convey("Nil pointers should only be allocated for defaults inside them", func() {
+			So(c.Backup, ShouldNotBeNil)
+			So(c.Backup.Tags, ShouldResemble, []string{"a", "b"})
+			So(c.Plain, ShouldBeNil)
+			So(c.List.Name, ShouldEqual, "node")
+			So(c.List.Next, ShouldBeNil)
+		})
+		Convey("Structs in slices and maps should get defaults", func() {
+			So(c.Servers[0].Port, ShouldEqual, 8080)
+			So(c.ByName["a"].Port, ShouldEqual, 8080)
+		})
+	})
+
+	Convey("Invalid defaults should be errors", t, func() {
+		bad := &struct {
+			X int `default:"x"`
+		}{}
+		So(ApplyDefaults(bad), ShouldNotBeNil)
+		So(ApplyDefaults(struct{}{}), ShouldNotBeNil)
+	})
+}
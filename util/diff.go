@@ -0,0 +1,309 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+type ChangeType int
+
+const (
+	ChangeAdded ChangeType = iota
+	ChangeRemoved
+	ChangeModified
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	}
+	return fmt.Sprintf("ChangeType(%d)", int(t))
+}
+
+// Change is a single difference found by Diff. From is nil for additions
+// and To is nil for removals.
+type Change struct {
+	Type ChangeType
+	Path string
+	From interface{}
+	To   interface{}
+}
+
+// Changes is the ordered result of Diff.
+type Changes []Change
+
+// Paths returns the path of every change, in order.
+func (c Changes) Paths() []string {
+	paths := make([]string, len(c))
+	for i, change := range c {
+		paths[i] = change.Path
+	}
+	return paths
+}
+
+// String renders the changes one per line, e.g.
+//
+//	~ Server.Port: 80 -> 8080
+//	+ Servers[2].Host: "c"
+//	- Labels[env]: "prod"
+func (c Changes) String() string {
+	var buf bytes.Buffer
+	for _, change := range c {
+		switch change.Type {
+		case ChangeAdded:
+			fmt.Fprintf(&buf, "+ %s: %s\n", change.Path, formatDiffValue(change.To))
+		case ChangeRemoved:
+			fmt.Fprintf(&buf, "- %s: %s\n", change.Path, formatDiffValue(change.From))
+		default:
+			fmt.Fprintf(&buf, "~ %s: %s -> %s\n", change.Path, formatDiffValue(change.From), formatDiffValue(change.To))
+		}
+	}
+	return buf.String()
+}
+
+func formatDiffValue(x interface{}) string {
+	if s, ok := x.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%+v", x)
+}
+
+type DiffOptions struct {
+	// IgnorePaths are QueryPaths-style patterns, e.g. "**.UpdatedAt", whose
+	// values are never compared.
+	IgnorePaths []string
+	// NilEqualsEmpty treats nil pointers, slices and maps as equal to
+	// zero-valued or empty ones.
+	NilEqualsEmpty bool
+	// UnorderedSlices compares slices as multisets, the way
+	// SliceUnorderedEqual does. Differences are reported as removals at
+	// indexes of the old slice and additions at indexes of the new one.
+	UnorderedSlices bool
+}
+
+// Diff returns the differences between a and b, covering structs, slices,
+// arrays, maps and pointers, in field, index and sorted key order.
+func Diff(a, b interface{}) Changes {
+	changes, _ := DiffWithOptions(a, b, DiffOptions{})
+	return changes
+}
+
+// DiffWithOptions is Diff with options; it only fails on malformed IgnorePaths.
+func DiffWithOptions(a, b interface{}, opts DiffOptions) (Changes, error) {
+	d := &differ{opts: opts, seen: map[[2]ptrKey]bool{}}
+	for _, pattern := range opts.IgnorePaths {
+		segs, err := splitPath(pattern)
+		if err != nil {
+			return nil, err
+		}
+		d.ignore = append(d.ignore, segs)
+	}
+	d.diff("", reflect.ValueOf(a), reflect.ValueOf(b))
+	return d.changes, nil
+}
+
+type differ struct {
+	opts    DiffOptions
+	ignore  [][]string
+	changes Changes
+	// pointer pairs already being compared, so cyclic values terminate
+	seen map[[2]ptrKey]bool
+}
+
+func (d *differ) add(t ChangeType, path string, a, b reflect.Value) {
+	d.changes = append(d.changes, Change{t, path, interfaceOrNil(a), interfaceOrNil(b)})
+}
+
+func (d *differ) ignored(path string) bool {
+	if len(d.ignore) == 0 {
+		return false
+	}
+	segs, _ := splitPath(path)
+	for _, pattern := range d.ignore {
+		if pathMatches(pattern, segs) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *differ) diff(path string, a, b reflect.Value) {
+	if d.ignored(path) {
+		return
+	}
+
+	if a.IsValid() && b.IsValid() && a.Kind() == reflect.Ptr && b.Kind() == reflect.Ptr && !a.IsNil() && !b.IsNil() {
+		pair := [2]ptrKey{{a.Pointer(), a.Type()}, {b.Pointer(), b.Type()}}
+		if d.seen[pair] {
+			return
+		}
+		d.seen[pair] = true
+		defer delete(d.seen, pair)
+	}
+
+	a, b = diffIndirect(a), diffIndirect(b)
+	switch {
+	case !a.IsValid() && !b.IsValid():
+		return
+	case !a.IsValid():
+		if !d.opts.NilEqualsEmpty || !isEmptyValue(b) {
+			d.add(ChangeAdded, path, a, b)
+		}
+		return
+	case !b.IsValid():
+		if !d.opts.NilEqualsEmpty || !isEmptyValue(a) {
+			d.add(ChangeRemoved, path, a, b)
+		}
+		return
+	case a.Type() != b.Type():
+		d.add(ChangeModified, path, a, b)
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Struct:
+		if !hasExportedFields(a.Type()) {
+			break
+		}
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if field := t.Field(i); field.PkgPath == "" {
+				d.diff(joinField(path, field.Name), a.Field(i), b.Field(i))
+			}
+		}
+		return
+	case reflect.Slice:
+		if a.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		if a.IsNil() != b.IsNil() && a.Len() == 0 && b.Len() == 0 {
+			if !d.opts.NilEqualsEmpty {
+				d.add(ChangeModified, path, a, b)
+			}
+			return
+		}
+		if d.opts.UnorderedSlices {
+			d.diffUnordered(path, a, b)
+			return
+		}
+		d.diffOrdered(path, a, b)
+		return
+	case reflect.Array:
+		d.diffOrdered(path, a, b)
+		return
+	case reflect.Map:
+		if a.IsNil() != b.IsNil() && a.Len() == 0 && b.Len() == 0 {
+			if !d.opts.NilEqualsEmpty {
+				d.add(ChangeModified, path, a, b)
+			}
+			return
+		}
+		d.diffMaps(path, a, b)
+		return
+	}
+
+	if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+		d.add(ChangeModified, path, a, b)
+	}
+}
+
+func (d *differ) diffOrdered(path string, a, b reflect.Value) {
+	for i := 0; i < a.Len() || i < b.Len(); i++ {
+		switch {
+		case i >= a.Len():
+			d.addUnlessIgnored(ChangeAdded, joinIndex(path, i), reflect.Value{}, b.Index(i))
+		case i >= b.Len():
+			d.addUnlessIgnored(ChangeRemoved, joinIndex(path, i), a.Index(i), reflect.Value{})
+		default:
+			d.diff(joinIndex(path, i), a.Index(i), b.Index(i))
+		}
+	}
+}
+
+func (d *differ) diffUnordered(path string, a, b reflect.Value) {
+	if SliceUnorderedEqual(a.Interface(), b.Interface()) {
+		return
+	}
+	matched := make([]bool, b.Len())
+	for i := 0; i < a.Len(); i++ {
+		found := false
+		for j := 0; j < b.Len(); j++ {
+			if !matched[j] && reflect.DeepEqual(a.Index(i).Interface(), b.Index(j).Interface()) {
+				matched[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			d.addUnlessIgnored(ChangeRemoved, joinIndex(path, i), a.Index(i), reflect.Value{})
+		}
+	}
+	for j := 0; j < b.Len(); j++ {
+		if !matched[j] {
+			d.addUnlessIgnored(ChangeAdded, joinIndex(path, j), reflect.Value{}, b.Index(j))
+		}
+	}
+}
+
+func (d *differ) diffMaps(path string, a, b reflect.Value) {
+	keys := a.MapKeys()
+	for _, key := range b.MapKeys() {
+		if !a.MapIndex(key).IsValid() {
+			keys = append(keys, key)
+		}
+	}
+	sortValues(keys)
+
+	for _, key := range keys {
+		keyPath := joinKey(path, key.Interface())
+		av, bv := a.MapIndex(key), b.MapIndex(key)
+		switch {
+		case !av.IsValid():
+			d.addUnlessIgnored(ChangeAdded, keyPath, av, bv)
+		case !bv.IsValid():
+			d.addUnlessIgnored(ChangeRemoved, keyPath, av, bv)
+		default:
+			d.diff(keyPath, av, bv)
+		}
+	}
+}
+
+func (d *differ) addUnlessIgnored(t ChangeType, path string, a, b reflect.Value) {
+	if !d.ignored(path) {
+		d.add(t, path, diffIndirect(a), diffIndirect(b))
+	}
+}
+
+// diffIndirect follows pointers and interfaces, returning the invalid Value for nil.
+func diffIndirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// isEmptyValue reports whether v is a zero value or an empty slice or map.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// interfaceOrNil is v.Interface(), or nil for the invalid Value.
+func interfaceOrNil(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
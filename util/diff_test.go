@@ -0,0 +1,85 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"testing"
+	"time"
+)
+
+type diffConfig struct {
+	Name      string
+	Port      int
+	Server    *ServerConfig
+	Tags      []string
+	Labels    map[string]string
+	UpdatedAt time.Time
+}
+
+func TestDiff(t *testing.T) {
+	Convey("When calling Diff", t, func() {
+		a := &diffConfig{
+			Name:   "app",
+			Port:   80,
+			Server: &ServerConfig{Host: "a"},
+			Tags:   []string{"x", "y"},
+			Labels: map[string]string{"env": "dev", "team": "core"},
+		}
+		b := &diffConfig{
+			Name:   "app",
+			Port:   8080,
+			Server: &ServerConfig{Host: "b"},
+			Tags:   []string{"y", "x", "z"},
+			Labels: map[string]string{"env": "prod", "owner": "me"},
+		}
+
+		Convey("Identical values should have no changes", func() {
+			So(Diff(a, a), ShouldBeEmpty)
+		})
+		Convey("Changes should be ordered and keyed by path", func() {
+			So(Diff(a, b), ShouldResemble, Changes{
+				{ChangeModified, "Port", 80, 8080},
+				{ChangeModified, "Server.Host", "a", "b"},
+				{ChangeModified, "Tags[0]", "x", "y"},
+				{ChangeModified, "Tags[1]", "y", "x"},
+				{ChangeAdded, "Tags[2]", nil, "z"},
+				{ChangeModified, "Labels[env]", "dev", "prod"},
+				{ChangeAdded, "Labels[owner]", nil, "me"},
+				{ChangeRemoved, "Labels[team]", "core", nil},
+			})
+		})
+		Convey("Nil pointers should be reported as additions and removals", func() {
+			b.Server = nil
+			changes := Diff(a, b)
+			So(changes[1].Type, ShouldEqual, ChangeRemoved)
+			So(changes[1].Path, ShouldEqual, "Server")
+			So(changes[1].From, ShouldResemble, *a.Server)
+		})
+		Convey("Ignored paths should be skipped", func() {
+			b.UpdatedAt = time.Now()
+			changes, err := DiffWithOptions(a, b, DiffOptions{IgnorePaths: []string{"Labels.*", "Tags", "**.Host", "UpdatedAt"}})
+			So(err, ShouldBeNil)
+			So(changes.Paths(), ShouldResemble, []string{"Port"})
+		})
+		Convey("Unordered slices should only report elements that differ", func() {
+			changes, err := DiffWithOptions(a, b, DiffOptions{UnorderedSlices: true, IgnorePaths: []string{"Labels", "Port", "Server"}})
+			So(err, ShouldBeNil)
+			So(changes, ShouldResemble, Changes{{ChangeAdded, "Tags[2]", nil, "z"}})
+		})
+		Convey("NilEqualsEmpty should treat nil like empty", func() {
+			x := &diffConfig{Tags: []string{}, Labels: map[string]string{}, Server: &ServerConfig{}}
+			y := &diffConfig{}
+			So(Diff(x, y).Paths(), ShouldResemble, []string{"Server", "Tags", "Labels"})
+			changes, _ := DiffWithOptions(x, y, DiffOptions{NilEqualsEmpty: true})
+			So(changes, ShouldBeEmpty)
+		})
+		Convey("Changes should render as a readable text diff", func() {
+			changes := Changes{
+				{ChangeModified, "Port", 80, 8080},
+				{ChangeAdded, "Tags[2]", nil, "z"},
+				{ChangeRemoved, "Labels[team]", "core", nil},
+			}
+			So(changes.String(), ShouldEqual, "~ Port: 80 -> 8080\n+ Tags[2]: \"z\"\n- Labels[team]: \"core\"\n")
+		})
+	})
+}
@@ -0,0 +1,347 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// docPosition is a 1-based line and column within a document.
+type docPosition struct {
+	Line   int
+	Column int
+}
+
+// docPositions maps the JSON Pointers of a document's nodes to where they
+// start.
+type docPositions map[string]docPosition
+
+// find returns the position of pointer, or of its closest ancestor that has
+// one, such as the enclosing flow collection in YAML.
+func (p docPositions) find(pointer string) (docPosition, bool) {
+	for {
+		if pos, ok := p[pointer]; ok {
+			return pos, true
+		}
+		i := strings.LastIndexByte(pointer, '/')
+		if i < 0 {
+			return docPosition{}, false
+		}
+		pointer = pointer[:i]
+	}
+}
+
+// lineIndex converts byte offsets into positions.
+type lineIndex struct {
+	data   []byte
+	starts []int
+}
+
+func newLineIndex(data []byte) *lineIndex {
+	starts := []int{0}
+	for i, c := range data {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &lineIndex{data, starts}
+}
+
+func (idx *lineIndex) position(offset int) docPosition {
+	line := sort.Search(len(idx.starts), func(i int) bool { return idx.starts[i] > offset }) - 1
+	col := utf8.RuneCount(idx.data[idx.starts[line]:offset]) + 1
+	return docPosition{line + 1, col}
+}
+
+// parseJSONPositions decodes the JSON document data into generic values,
+// with numbers as float64, and records where every node and every object key
+// starts.
+func parseJSONPositions(data []byte) (interface{}, docPositions, docPositions, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		if syntax, ok := err.(*json.SyntaxError); ok {
+			// Offset is just past the offending byte
+			offset := int(syntax.Offset) - 1
+			if offset < 0 {
+				offset = 0
+			}
+			pos := newLineIndex(data).position(offset)
+			return nil, nil, nil, fmt.Errorf("line %d, column %d: %v", pos.Line, pos.Column, err)
+		}
+		return nil, nil, nil, err
+	}
+	// the document is valid, so the scanner need not check its syntax
+	s := &jsonPosScanner{data: data, lines: newLineIndex(data), positions: docPositions{}, keys: docPositions{}}
+	s.value("")
+	return v, s.positions, s.keys, nil
+}
+
+type jsonPosScanner struct {
+	data      []byte
+	pos       int
+	lines     *lineIndex
+	positions docPositions
+	keys      docPositions
+}
+
+func (s *jsonPosScanner) skipSpace() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\r', '\n':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (s *jsonPosScanner) value(pointer string) {
+	s.skipSpace()
+	s.positions[pointer] = s.lines.position(s.pos)
+	switch s.data[s.pos] {
+	case '{':
+		s.pos++
+		for {
+			s.skipSpace()
+			if s.data[s.pos] == '}' {
+				s.pos++
+				return
+			}
+			if s.data[s.pos] == ',' {
+				s.pos++
+				s.skipSpace()
+			}
+			keyPos := s.lines.position(s.pos)
+			child := joinJSONPointer(pointer, s.str())
+			s.keys[child] = keyPos
+			s.skipSpace()
+			s.pos++ // ':'
+			s.value(child)
+			s.skipSpace()
+		}
+	case '[':
+		s.pos++
+		for i := 0; ; i++ {
+			s.skipSpace()
+			if s.data[s.pos] == ']' {
+				s.pos++
+				return
+			}
+			if s.data[s.pos] == ',' {
+				s.pos++
+			}
+			s.value(pointer + "/" + strconv.Itoa(i))
+			s.skipSpace()
+		}
+	case '"':
+		s.str()
+	default:
+		// numbers and literals
+		for s.pos < len(s.data) && strings.IndexByte(" \t\r\n,]}", s.data[s.pos]) < 0 {
+			s.pos++
+		}
+	}
+}
+
+// str consumes a string literal and returns its value.
+func (s *jsonPosScanner) str() string {
+	start := s.pos
+	s.pos++
+	for s.data[s.pos] != '"' {
+		if s.data[s.pos] == '\\' {
+			s.pos++
+		}
+		s.pos++
+	}
+	s.pos++
+	var str string
+	json.Unmarshal(s.data[start:s.pos], &str)
+	return str
+}
+
+// yamlPositions records where the nodes and mapping keys of the block-style
+// YAML document data start, by following its indentation. Nodes within flow
+// collections ({...} and [...]) are not recorded; find falls back to the
+// collection.
+func yamlPositions(data []byte) (docPositions, docPositions) {
+	positions, keys := docPositions{}, docPositions{}
+	type frame struct {
+		indent  int
+		pointer string
+		seq     bool
+		next    int
+	}
+	var stack []*frame
+	// a key or item whose value follows on the next lines
+	var pending *frame
+	// lines indented deeper than this belong to a block scalar
+	blockIndent := -1
+
+	for lineNo, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		content := strings.TrimLeft(line, " ")
+		indent := len(line) - len(content)
+		if blockIndent >= 0 {
+			if content == "" || indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+		if content == "" || content[0] == '#' || content == "---" || content == "..." || strings.HasPrefix(content, "--- ") {
+			continue
+		}
+
+		isItem := content == "-" || strings.HasPrefix(content, "- ")
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.indent > indent || top.indent == indent && top.seq && !isItem {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			break
+		}
+		if pending != nil {
+			if indent > pending.indent || indent == pending.indent && isItem {
+				stack = append(stack, &frame{indent: indent, pointer: pending.pointer, seq: isItem})
+			}
+			pending = nil
+		}
+		if len(stack) == 0 {
+			stack = append(stack, &frame{indent: indent, seq: isItem})
+			if _, ok := positions[""]; !ok {
+				positions[""] = docPosition{lineNo + 1, indent + 1}
+			}
+		}
+
+		// a line may open several nodes, as in "- - a" or "- key: value"
+		for content != "" {
+			top := stack[len(stack)-1]
+			col := indent + 1
+			if content == "-" || strings.HasPrefix(content, "- ") {
+				if !top.seq {
+					break
+				}
+				pointer := top.pointer + "/" + strconv.Itoa(top.next)
+				top.next++
+				rest := strings.TrimLeft(strings.TrimPrefix(content, "-"), " ")
+				restIndent := indent + len(content) - len(rest)
+				if rest == "" || rest[0] == '#' {
+					positions[pointer] = docPosition{lineNo + 1, col}
+					pending = &frame{indent: indent, pointer: pointer}
+					break
+				}
+				positions[pointer] = docPosition{lineNo + 1, restIndent + 1}
+				if strings.HasPrefix(rest, "- ") || rest == "-" {
+					stack = append(stack, &frame{indent: restIndent, pointer: pointer, seq: true})
+				} else if _, _, ok := splitYAMLKey(rest); ok {
+					stack = append(stack, &frame{indent: restIndent, pointer: pointer})
+				} else if isYAMLBlockScalar(rest) {
+					blockIndent = indent
+					break
+				} else {
+					break
+				}
+				content, indent = rest, restIndent
+				continue
+			}
+
+			key, value, ok := splitYAMLKey(content)
+			if !ok || top.seq {
+				break
+			}
+			pointer := joinJSONPointer(top.pointer, key)
+			keys[pointer] = docPosition{lineNo + 1, col}
+			if value == "" || value[0] == '#' {
+				positions[pointer] = docPosition{lineNo + 1, col}
+				pending = &frame{indent: indent, pointer: pointer}
+				break
+			}
+			valueCol := indent + len(content) - len(value) + 1
+			positions[pointer] = docPosition{lineNo + 1, valueCol}
+			if isYAMLBlockScalar(value) {
+				blockIndent = indent
+			}
+			break
+		}
+	}
+	return positions, keys
+}
+
+// splitYAMLKey splits a block mapping entry such as `key: value` or
+// `"quoted key": value` into its key and the rest of the line.
+func splitYAMLKey(content string) (string, string, bool) {
+	if content == "" {
+		return "", "", false
+	}
+	var key string
+	rest := ""
+	switch content[0] {
+	case '"', '\'':
+		end := closingQuote(content)
+		if end < 0 {
+			return "", "", false
+		}
+		key = content[:end+1]
+		if content[0] == '"' {
+			unquoted, err := strconv.Unquote(key)
+			if err != nil {
+				return "", "", false
+			}
+			key = unquoted
+		} else {
+			key = strings.ReplaceAll(key[1:len(key)-1], "''", "'")
+		}
+		rest = strings.TrimLeft(content[end+1:], " ")
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+		rest = rest[1:]
+	case '[', '{', '|', '>', '&', '*', '!', '%', '@', '`':
+		return "", "", false
+	default:
+		i := strings.Index(content, ": ")
+		if i < 0 && strings.HasSuffix(content, ":") {
+			i = len(content) - 1
+		}
+		if i < 0 {
+			return "", "", false
+		}
+		if hash := strings.Index(content, " #"); hash >= 0 && hash < i {
+			return "", "", false
+		}
+		key = strings.TrimRight(content[:i], " ")
+		rest = content[i+1:]
+	}
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return "", "", false
+	}
+	return key, strings.TrimLeft(rest, " \t"), true
+}
+
+// closingQuote returns the index of the quote closing the string that
+// starts s, or -1.
+func closingQuote(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// isYAMLBlockScalar reports whether value introduces a literal or folded
+// block scalar, such as "|" or ">-".
+func isYAMLBlockScalar(value string) bool {
+	return value != "" && (value[0] == '|' || value[0] == '>')
+}
@@ -0,0 +1,397 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReadDotenvFromFile reads the dotenv file filename into result, which must
+// point to a map, such as a map[string]string, or to a struct whose fields
+// are named as LoadEnv names them, without a prefix.
+//
+// Each line of the file is a comment or a `KEY=value` assignment, optionally
+// prefixed by "export". Unquoted values end at a " #" comment and are
+// trimmed. Single-quoted values are taken literally; double-quoted values
+// may contain the escapes \n, \r, \t, \", \\ and \$. Quoted values may span
+// lines. Unquoted and double-quoted values expand $VAR, ${VAR} and
+// ${VAR:-default} from the variables assigned before them and then from the
+// process environment.
+func ReadDotenvFromFile(filename string, result interface{}) error {
+	rawFileData, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return unmarshalDotenv(rawFileData, result)
+}
+
+// LoadDotenv sets the process environment from the dotenv file filename.
+// Variables that are already set are only replaced if override is true.
+func LoadDotenv(filename string, override bool) error {
+	rawFileData, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	entries, err := parseDotenv(rawFileData, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, set := os.LookupEnv(e.key); set && !override {
+			continue
+		}
+		if err := os.Setenv(e.key, e.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDotenvToFile writes data, a map or a struct, to filename as a dotenv
+// file with its keys sorted. Struct fields are named as LoadEnv names them.
+func WriteDotenvToFile(filename string, data interface{}, perm os.FileMode) error {
+	rawData, err := marshalDotenv(data)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, rawData, perm)
+}
+
+// UpdateDotenvFile sets values in the dotenv file filename, creating it if
+// needed. Keys already assigned are rewritten where they are, keeping the
+// comments and order of the file; new keys are appended in sorted order.
+func UpdateDotenvFile(filename string, values map[string]string, perm os.FileMode) error {
+	rawFileData, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	entries, err := parseDotenv(rawFileData, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	last := 0
+	assigned := map[string]bool{}
+	for _, e := range entries {
+		value, ok := values[e.key]
+		if !ok {
+			continue
+		}
+		buf.Write(rawFileData[last:e.start])
+		if e.export {
+			buf.WriteString("export ")
+		}
+		buf.WriteString(e.key + "=" + quoteDotenvValue(value))
+		last = e.end
+		assigned[e.key] = true
+	}
+	buf.Write(rawFileData[last:])
+
+	var added []string
+	for key := range values {
+		if !assigned[key] {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	if len(added) > 0 && buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	for _, key := range added {
+		if !dotenvKeyRe.MatchString(key) {
+			return fmt.Errorf("invalid variable name %q", key)
+		}
+		buf.WriteString(key + "=" + quoteDotenvValue(values[key]) + "\n")
+	}
+	return ioutil.WriteFile(filename, buf.Bytes(), perm)
+}
+
+type dotenvCodec struct{}
+
+func (dotenvCodec) Unmarshal(data []byte, v interface{}) error {
+	return unmarshalDotenv(data, v)
+}
+
+func (dotenvCodec) Marshal(v interface{}) ([]byte, error) {
+	return marshalDotenv(v)
+}
+
+func (dotenvCodec) Detect(data []byte) bool {
+	entries, err := parseDotenv(data, func(string) (string, bool) { return "", false })
+	return err == nil && len(entries) > 0
+}
+
+func unmarshalDotenv(data []byte, result interface{}) error {
+	entries, err := parseDotenv(data, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	root, err := settableRoot(result)
+	if err != nil {
+		return err
+	}
+	if root.Kind() == reflect.Map || root.Kind() == reflect.Interface {
+		vars := map[string]interface{}{}
+		for _, e := range entries {
+			vars[e.key] = e.value
+		}
+		return Decode(vars, result, DecodeOptions{})
+	}
+	environ := make([]string, len(entries))
+	for i, e := range entries {
+		environ[i] = e.key + "=" + e.value
+	}
+	return LoadEnvFrom("", environ, result)
+}
+
+func marshalDotenv(data interface{}) ([]byte, error) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	vars := map[string]string{}
+	switch v.Kind() {
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			vars[DefaultStringCoercer.Format(key)] = DefaultStringCoercer.Format(v.MapIndex(key))
+		}
+	case reflect.Struct:
+		encodeEnv(v, "", vars)
+	default:
+		return nil, fmt.Errorf("cannot encode %T as dotenv, it is not a struct or map", data)
+	}
+
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		if !dotenvKeyRe.MatchString(key) {
+			return nil, fmt.Errorf("invalid variable name %q", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for _, key := range keys {
+		buf.WriteString(key + "=" + quoteDotenvValue(vars[key]) + "\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeEnv adds the variables LoadEnv would read v from, named with prefix,
+// to vars.
+func encodeEnv(v reflect.Value, prefix string, vars map[string]string) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.IsNil() {
+		return
+	}
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + "_" + name
+	}
+	if prefix != "" && (isLeafType(v.Type()) || isFlagContainer(v.Type())) {
+		vars[prefix] = DefaultStringCoercer.Format(v)
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, field := range exportedFields(v.Type()) {
+			if field.Tag.Get("env") == "-" {
+				continue
+			}
+			fv := v.FieldByIndex(field.Index)
+			if field.Anonymous && field.Tag.Get("env") == "" {
+				encodeEnv(fv, prefix, vars)
+			} else if field.PkgPath == "" {
+				encodeEnv(fv, join(envFieldName(field)), vars)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			encodeEnv(v.Index(i), join(strconv.Itoa(i)), vars)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			encodeEnv(v.MapIndex(key), join(strings.ToUpper(DefaultStringCoercer.Format(key))), vars)
+		}
+	}
+}
+
+var (
+	dotenvKeyRe   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+	dotenvBareRe  = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+	dotenvNameRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+	dotenvEscapes = map[byte]string{'n': "\n", 'r': "\r", 't': "\t", '"': `"`, '\\': `\`, '$': "$"}
+)
+
+// quoteDotenvValue quotes value if it would not be read back as is.
+func quoteDotenvValue(value string) string {
+	if dotenvBareRe.MatchString(value) {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// dotenvEntry is an assignment in a dotenv file, spanning the bytes
+// start:end, before its trailing comment.
+type dotenvEntry struct {
+	key, value string
+	export     bool
+	start, end int
+}
+
+// parseDotenv parses the assignments of a dotenv file, expanding variables
+// from those assigned before and then from lookup.
+func parseDotenv(data []byte, lookup func(string) (string, bool)) ([]dotenvEntry, error) {
+	src := string(data)
+	lines := newLineIndex(data)
+	fail := func(offset int, format string, args ...interface{}) error {
+		pos := lines.position(offset)
+		return fmt.Errorf("line %d, column %d: %s", pos.Line, pos.Column, fmt.Sprintf(format, args...))
+	}
+	vars := map[string]string{}
+	lookupVar := func(name string) (string, bool) {
+		if v, ok := vars[name]; ok {
+			return v, true
+		}
+		return lookup(name)
+	}
+
+	var entries []dotenvEntry
+	pos := 0
+	for pos < len(src) {
+		lineEnd := strings.IndexByte(src[pos:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(src)
+		} else {
+			lineEnd += pos
+		}
+		line := strings.TrimRight(src[pos:lineEnd], "\r")
+		content := strings.TrimLeft(line, " \t")
+		if content == "" || content[0] == '#' {
+			pos = lineEnd + 1
+			continue
+		}
+
+		e := dotenvEntry{start: pos + len(line) - len(content)}
+		if strings.HasPrefix(content, "export ") || strings.HasPrefix(content, "export\t") {
+			e.export = true
+			content = strings.TrimLeft(content[len("export"):], " \t")
+		}
+		keyOffset := pos + len(line) - len(content)
+		eq := strings.IndexByte(content, '=')
+		if eq < 0 {
+			return nil, fail(keyOffset, "expected KEY=value")
+		}
+		e.key = strings.TrimRight(content[:eq], " \t")
+		if !dotenvKeyRe.MatchString(e.key) {
+			return nil, fail(keyOffset, "invalid variable name %q", e.key)
+		}
+
+		valueStart := keyOffset + eq + 1
+		for valueStart < len(src) && (src[valueStart] == ' ' || src[valueStart] == '\t') {
+			valueStart++
+		}
+		var rest int
+		switch {
+		case valueStart < len(src) && src[valueStart] == '\'':
+			end := strings.IndexByte(src[valueStart+1:], '\'')
+			if end < 0 {
+				return nil, fail(valueStart, "unterminated quoted value")
+			}
+			e.value = src[valueStart+1 : valueStart+1+end]
+			rest = valueStart + end + 2
+		case valueStart < len(src) && src[valueStart] == '"':
+			i := valueStart + 1
+			for ; i < len(src) && src[i] != '"'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(src) {
+				return nil, fail(valueStart, "unterminated quoted value")
+			}
+			e.value = expandDotenv(src[valueStart+1:i], true, lookupVar)
+			rest = i + 1
+		default:
+			raw := src[valueStart : pos+len(line)]
+			if i := strings.Index(raw, " #"); i >= 0 {
+				raw = raw[:i]
+			} else if i := strings.Index(raw, "\t#"); i >= 0 {
+				raw = raw[:i]
+			}
+			raw = strings.TrimRight(raw, " \t")
+			e.value = expandDotenv(raw, false, lookupVar)
+			rest = valueStart + len(raw)
+		}
+		e.end = rest
+
+		// only a comment may follow a quoted value
+		next := strings.IndexByte(src[rest:], '\n')
+		if next < 0 {
+			next = len(src)
+		} else {
+			next += rest
+		}
+		if trailing := strings.TrimSpace(src[rest:next]); trailing != "" && trailing[0] != '#' {
+			return nil, fail(rest, "unexpected %q after value", trailing)
+		}
+		vars[e.key] = e.value
+		entries = append(entries, e)
+		pos = next + 1
+	}
+	return entries, nil
+}
+
+// expandDotenv replaces $VAR, ${VAR} and ${VAR:-default} in the value s
+// using lookup, with unset variables empty, and resolves the escapes of
+// double-quoted values if quoted is set. \$ stands for a literal $ either way.
+func expandDotenv(s string, quoted bool, lookup func(string) (string, bool)) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && (s[i+1] == '$' || quoted && dotenvEscapes[s[i+1]] != ""):
+			b.WriteString(dotenvEscapes[s[i+1]])
+			i++
+		case s[i] == '$' && strings.HasPrefix(s[i+1:], "{"):
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			name, def := s[i+2:i+end], ""
+			hasDefault := false
+			if j := strings.Index(name, ":-"); j >= 0 {
+				name, def, hasDefault = name[:j], name[j+2:], true
+			}
+			value, ok := lookup(name)
+			if hasDefault && (!ok || value == "") {
+				value = def
+			}
+			b.WriteString(value)
+			i += end
+		case s[i] == '$' && dotenvNameRe.MatchString(s[i+1:]):
+			name := dotenvNameRe.FindString(s[i+1:])
+			value, _ := lookup(name)
+			b.WriteString(value)
+			i += len(name)
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
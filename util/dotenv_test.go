@@ -0,0 +1,124 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const dotenvDocument = `# database settings
+export DB_HOST=localhost
+DB_PORT = 5432 # trailing comment
+DB_URL="postgres://${DB_HOST}:$DB_PORT/app\tdb"
+GREETING='hello
+  $world'
+EMPTY=
+FALLBACK=${UNSET_DOTENV_VAR:-default}
+PRICE="\$5"
+`
+
+type dotenvDB struct {
+	Host string
+	Port int
+}
+
+type dotenvConfig struct {
+	DB       dotenvDB
+	Greeting string
+	Tags     []string
+	Labels   map[string]string
+	Secret   string `env:"-"`
+}
+
+func TestDotenv(t *testing.T) {
+	Convey("When parsing a dotenv file", t, func() {
+		var vars map[string]string
+		So(unmarshalDotenv([]byte(dotenvDocument), &vars), ShouldBeNil)
+
+		Convey("Values should be unquoted, unescaped and expanded", func() {
+			So(vars, ShouldResemble, map[string]string{
+				"DB_HOST":  "localhost",
+				"DB_PORT":  "5432",
+				"DB_URL":   "postgres://localhost:5432/app\tdb",
+				"GREETING": "hello\n  $world",
+				"EMPTY":    "",
+				"FALLBACK": "default",
+				"PRICE":    "$5",
+			})
+		})
+
+		Convey("Invalid lines should fail with their position", func() {
+			for doc, msg := range map[string]string{
+				"A=1\nnot an assignment": "line 2, column 1: expected KEY=value",
+				"1A=x":                   `line 1, column 1: invalid variable name "1A"`,
+				"A=\"open":               "line 1, column 3: unterminated quoted value",
+				"A='x' y":                `line 1, column 6: unexpected "y" after value`,
+			} {
+				var m map[string]string
+				err := unmarshalDotenv([]byte(doc), &m)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, msg)
+			}
+		})
+	})
+
+	Convey("When decoding a dotenv file into a struct", t, func() {
+		var c dotenvConfig
+		So(unmarshalDotenv([]byte("DB_HOST=db\nDB_PORT=1\nTAGS=a,b\nLABELS_ENV=prod\nSECRET=x\n"), &c), ShouldBeNil)
+
+		Convey("Variables should be matched to field paths", func() {
+			So(c, ShouldResemble, dotenvConfig{
+				DB:     dotenvDB{"db", 1},
+				Tags:   []string{"a", "b"},
+				Labels: map[string]string{"env": "prod"},
+			})
+		})
+
+		Convey("Encoding it should give the same variables back", func() {
+			c.Greeting, c.Tags = "hi there", nil
+			data, err := marshalDotenv(&c)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "DB_HOST=db\nDB_PORT=1\nGREETING=\"hi there\"\nLABELS=env=prod\n")
+		})
+	})
+
+	Convey("When updating a dotenv file", t, func() {
+		dir, err := ioutil.TempDir("", "dotenv")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, ".env")
+		So(ioutil.WriteFile(path, []byte("# comment\nexport A=1 # one\n\nB='two'\nC=3"), 0644), ShouldBeNil)
+
+		Convey("Comments and order should be kept and new keys appended", func() {
+			So(UpdateDotenvFile(path, map[string]string{"B": "new value", "A": "2", "D": "4"}, 0644), ShouldBeNil)
+			data, _ := ioutil.ReadFile(path)
+			So(string(data), ShouldEqual, "# comment\nexport A=2 # one\n\nB=\"new value\"\nC=3\nD=4\n")
+
+			var vars map[string]string
+			So(ReadConfigFile(path, &vars), ShouldBeNil)
+			So(vars["B"], ShouldEqual, "new value")
+		})
+	})
+
+	Convey("When loading a dotenv file into the environment", t, func() {
+		dir, err := ioutil.TempDir("", "dotenv")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "test.env")
+		So(ioutil.WriteFile(path, []byte("DOTENV_TEST_SET=file\nDOTENV_TEST_NEW=file\n"), 0644), ShouldBeNil)
+		os.Setenv("DOTENV_TEST_SET", "env")
+		defer os.Unsetenv("DOTENV_TEST_SET")
+		defer os.Unsetenv("DOTENV_TEST_NEW")
+
+		Convey("Variables already set should only be replaced on override", func() {
+			So(LoadDotenv(path, false), ShouldBeNil)
+			So(os.Getenv("DOTENV_TEST_SET"), ShouldEqual, "env")
+			So(os.Getenv("DOTENV_TEST_NEW"), ShouldEqual, "file")
+			So(LoadDotenv(path, true), ShouldBeNil)
+			So(os.Getenv("DOTENV_TEST_SET"), ShouldEqual, "file")
+		})
+	})
+}
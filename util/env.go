@@ -0,0 +1,199 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UnknownEnvError is returned by LoadEnv for variables that carry the prefix
+// but match no field. All other variables have still been applied.
+type UnknownEnvError struct {
+	Names []string
+}
+
+func (e *UnknownEnvError) Error() string {
+	return fmt.Sprintf("unknown environment variables: %s", strings.Join(e.Names, ", "))
+}
+
+// LoadEnv sets fields of obj, which must be a pointer, from environment
+// variables named after their paths: with prefix "APP", APP_SERVER_PORT sets
+// Server.Port. Field names are converted to upper snake case (ListenPort
+// becomes LISTEN_PORT) unless an `env:"NAME"` tag names them. Slices and
+// maps are set whole ("a,b" or "k=v,k2=v2"), or element by element:
+// APP_SERVERS_0_HOST sets Servers[0].Host and APP_LABELS_ENV sets
+// Labels[env] (map keys are lower-cased). Values are parsed with
+// DefaultStringCoercer. Variables that carry the prefix but match no field
+// are reported through an *UnknownEnvError. prefix must not be empty.
+func LoadEnv(prefix string, obj interface{}) error {
+	if prefix == "" {
+		return fmt.Errorf("LoadEnv needs a prefix; use LoadEnvFrom to read unprefixed variables")
+	}
+	return LoadEnvFrom(prefix, os.Environ(), obj)
+}
+
+// LoadEnvFrom is LoadEnv reading "NAME=value" entries from environ instead
+// of the process environment. With an empty prefix every entry is read, and
+// unknown ones are ignored.
+func LoadEnvFrom(prefix string, environ []string, obj interface{}) error {
+	_, err := loadEnv(prefix, environ, obj)
+	return err
+}
+
+// envBinding records that variable Name set the field at Path.
+type envBinding struct {
+	Path string
+	Name string
+}
+
+func loadEnv(prefix string, environ []string, obj interface{}) ([]envBinding, error) {
+	root, err := settableRoot(obj)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+
+	vars := map[string]string{}
+	for _, entry := range environ {
+		if kv := strings.SplitN(entry, "=", 2); len(kv) == 2 && strings.HasPrefix(kv[0], prefix) {
+			vars[kv[0]] = kv[1]
+		}
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var bindings []envBinding
+	var unknown []string
+	for _, name := range names {
+		path, ok := matchEnvPath(root.Type(), "", strings.TrimPrefix(name, prefix))
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		if err := DefaultStringCoercer.SetPath(obj, path, vars[name]); err != nil {
+			return bindings, fmt.Errorf("%s: %v", name, err)
+		}
+		bindings = append(bindings, envBinding{path, name})
+	}
+
+	if len(unknown) > 0 && prefix != "" {
+		return bindings, &UnknownEnvError{unknown}
+	}
+	return bindings, nil
+}
+
+// envFieldName returns the variable name segment for field.
+func envFieldName(field reflect.StructField) string {
+	if name, _ := parseTag(field.Tag.Get("env")); name != "" {
+		return name
+	}
+	return strings.ToUpper(strings.Join(splitWords(field.Name), "_"))
+}
+
+// matchEnvPath finds the path below type t, itself at path, named by the
+// variable name suffix name.
+func matchEnvPath(t reflect.Type, path, name string) (string, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if name == "" || isLeafType(t) {
+		return "", false
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := exportedFields(t)
+		for _, field := range fields {
+			// structs are only set field by field
+			if field.Tag.Get("env") != "-" && envFieldName(field) == name &&
+				(isLeafType(field.Type) || !isStructOrPtrToStruct(field.Type)) {
+				return joinField(path, field.Name), true
+			}
+		}
+		// prefer the longest field name, so SERVER_PORT beats SERVER
+		sort.SliceStable(fields, func(i, j int) bool {
+			return len(envFieldName(fields[i])) > len(envFieldName(fields[j]))
+		})
+		for _, field := range fields {
+			if field.Tag.Get("env") == "-" {
+				continue
+			}
+			if field.Anonymous && field.Tag.Get("env") == "" {
+				// embedded fields are promoted, so they keep the parent's path
+				if p, ok := matchEnvPath(field.Type, path, name); ok {
+					return p, true
+				}
+				continue
+			}
+			if fieldName := envFieldName(field); strings.HasPrefix(name, fieldName+"_") {
+				if p, ok := matchEnvPath(field.Type, joinField(path, field.Name), name[len(fieldName)+1:]); ok {
+					return p, true
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		head, rest := name, ""
+		if i := strings.IndexByte(name, '_'); i >= 0 {
+			head, rest = name[:i], name[i+1:]
+		}
+		idx, err := strconv.Atoi(head)
+		if err != nil || idx < 0 {
+			return "", false
+		}
+		if rest == "" {
+			return joinIndex(path, idx), true
+		}
+		return matchEnvPath(t.Elem(), joinIndex(path, idx), rest)
+	case reflect.Map:
+		head, rest := name, ""
+		if i := strings.IndexByte(name, '_'); i >= 0 && !isLeafType(t.Elem()) {
+			head, rest = name[:i], name[i+1:]
+		}
+		keyPath := joinKey(path, strings.ToLower(head))
+		if rest == "" {
+			return keyPath, true
+		}
+		return matchEnvPath(t.Elem(), keyPath, rest)
+	}
+	return "", false
+}
+
+// exportedFields returns the exported fields of struct type t, plus embedded
+// structs whatever their visibility.
+func exportedFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); field.PkgPath == "" || field.Anonymous {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// isLeafType reports whether values of t are set as a whole from a single
+// string rather than field by field.
+func isLeafType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType || t == durationType || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return !hasExportedFields(t)
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	case reflect.Array, reflect.Map:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"os"
+	"testing"
+	"time"
+)
+
+type envBase struct {
+	Version int
+}
+
+type envServer struct {
+	Host       string
+	ListenPort int
+	Timeout    time.Duration
+}
+
+type envConfig struct {
+	envBase
+	Server     envServer
+	ServerName string
+	Servers    []envServer
+	Tags       []string
+	Labels     map[string]string
+	ByName     map[string]*envServer
+	Token      string `env:"SECRET_TOKEN"`
+	HTTPProxy  string
+	Skip       string `env:"-"`
+}
+
+func TestSplitWords(t *testing.T) {
+	Convey("Identifiers should be split at case changes and separators", t, func() {
+		So(splitWords("HTTPServerPort2"), ShouldResemble, []string{"HTTP", "Server", "Port2"})
+		So(splitWords("listenPort"), ShouldResemble, []string{"listen", "Port"})
+		So(splitWords("max_conn-count"), ShouldResemble, []string{"max", "conn", "count"})
+		So(splitWords("ID"), ShouldResemble, []string{"ID"})
+	})
+}
+
+func TestLoadEnv(t *testing.T) {
+	Convey("When loading variables into a struct", t, func() {
+		c := &envConfig{}
+		err := LoadEnvFrom("APP", []string{
+			"APP_VERSION=3",
+			"APP_SERVER_HOST=h",
+			"APP_SERVER_LISTEN_PORT=80",
+			"APP_SERVER_TIMEOUT=5s",
+			"APP_SERVER_NAME=sn",
+			"APP_SERVERS_1_HOST=s1",
+			"APP_TAGS=a,b",
+			"APP_LABELS=x=1",
+			"APP_LABELS_TEAM_NAME=core",
+			"APP_BY_NAME_WEB_HOST=w",
+			"APP_SECRET_TOKEN=t",
+			"APP_HTTP_PROXY=p",
+			"APP_SERVER=x",
+			"APP_SKIP=1",
+			"OTHER=1",
+		}, c)
+
+		Convey("Fields should be set by their snake case paths", func() {
+			So(c.Version, ShouldEqual, 3)
+			So(c.Server, ShouldResemble, envServer{"h", 80, 5 * time.Second})
+			So(c.ServerName, ShouldEqual, "sn")
+			So(c.Token, ShouldEqual, "t")
+			So(c.HTTPProxy, ShouldEqual, "p")
+		})
+		Convey("Slices and maps should be set whole or by element", func() {
+			So(c.Servers, ShouldHaveLength, 2)
+			So(c.Servers[1].Host, ShouldEqual, "s1")
+			So(c.Tags, ShouldResemble, []string{"a", "b"})
+			So(c.Labels, ShouldResemble, map[string]string{"x": "1", "team_name": "core"})
+			So(c.ByName["web"].Host, ShouldEqual, "w")
+		})
+		Convey("Struct, skipped and unknown names should be reported", func() {
+			So(err, ShouldNotBeNil)
+			So(err.(*UnknownEnvError).Names, ShouldResemble, []string{"APP_SERVER", "APP_SKIP"})
+			So(c.Skip, ShouldEqual, "")
+		})
+	})
+
+	Convey("Values that cannot be parsed should be errors", t, func() {
+		err := LoadEnvFrom("APP", []string{"APP_SERVER_LISTEN_PORT=x"}, &envConfig{})
+		So(err, ShouldNotBeNil)
+		_, unknown := err.(*UnknownEnvError)
+		So(unknown, ShouldBeFalse)
+	})
+
+	Convey("LoadEnv should read the process environment with a prefix", t, func() {
+		os.Setenv("UTILTEST_SERVER_HOST", "env-host")
+		defer os.Unsetenv("UTILTEST_SERVER_HOST")
+		c := &envConfig{}
+		So(LoadEnv("UTILTEST", c), ShouldBeNil)
+		So(c.Server.Host, ShouldEqual, "env-host")
+		So(LoadEnv("", c), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,161 @@
+package util
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// BindFlags registers a flag on fs for every leaf field of obj, which must be
+// a pointer to a struct. Flags are named after the field path in kebab case,
+// with nested structs separated by dots (Server.ListenPort becomes
+// "server.listen-port"), and prefixed with prefix and a dot if it is
+// non-empty. A `flag:"name"` tag renames a field's segment and `flag:"-"`
+// skips it. Usage comes from a `help` tag and the default shown is the
+// field's current value, if not zero. Parsed flags are written into obj with
+// DefaultStringCoercer, so slices ("a,b") and maps ("k=v") are set whole.
+// Nil pointers to structs are only allocated when one of their flags is set.
+func BindFlags(fs *flag.FlagSet, obj interface{}, prefix string) error {
+	_, err := bindFlags(fs, obj, prefix, func(path, s string) error {
+		return DefaultStringCoercer.SetPath(obj, path, s)
+	})
+	return err
+}
+
+// bindFlags registers the flags of obj, calling set with the field path and
+// flag value for every flag parsed. It returns the field path of every flag
+// by name.
+func bindFlags(fs *flag.FlagSet, obj interface{}, prefix string, set func(path, s string) error) (map[string]string, error) {
+	root, err := settableRoot(obj)
+	if err != nil {
+		return nil, err
+	}
+	if root.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot bind flags to %s", root.Type())
+	}
+	b := &flagBinder{fs: fs, obj: obj, set: set, paths: map[string]string{}, visiting: map[reflect.Type]bool{}}
+	if err := b.bind(root.Type(), "", prefix); err != nil {
+		return nil, err
+	}
+	return b.paths, nil
+}
+
+type flagBinder struct {
+	fs    *flag.FlagSet
+	obj   interface{}
+	set   func(path, s string) error
+	paths map[string]string
+	// struct types on the current descent, so recursive types end
+	visiting map[reflect.Type]bool
+}
+
+func (b *flagBinder) bind(t reflect.Type, path, name string) error {
+	if b.visiting[t] {
+		return nil
+	}
+	b.visiting[t] = true
+	defer delete(b.visiting, t)
+
+	for _, field := range exportedFields(t) {
+		tag, _ := parseTag(field.Tag.Get("flag"))
+		if tag == "-" {
+			continue
+		}
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if field.Anonymous && tag == "" && ft.Kind() == reflect.Struct && !isLeafType(ft) {
+			// embedded fields are promoted, so they keep the parent's path
+			if err := b.bind(ft, path, name); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fieldName := tag
+		if fieldName == "" {
+			fieldName = strings.ToLower(strings.Join(splitWords(field.Name), "-"))
+		}
+		if name != "" {
+			fieldName = name + "." + fieldName
+		}
+		fieldPath := joinField(path, field.Name)
+
+		switch {
+		case isLeafType(ft) || isFlagContainer(ft):
+			if err := b.register(fieldName, fieldPath, field); err != nil {
+				return err
+			}
+		case ft.Kind() == reflect.Struct:
+			if err := b.bind(ft, fieldPath, fieldName); err != nil {
+				return err
+			}
+		}
+		// other containers, such as slices of structs, have no fixed paths to bind
+	}
+	return nil
+}
+
+func (b *flagBinder) register(name, path string, field reflect.StructField) error {
+	if b.fs.Lookup(name) != nil {
+		return fmt.Errorf("flag %q for %s is already defined", name, path)
+	}
+	value := &fieldFlag{obj: b.obj, path: path, typ: field.Type, set: b.set}
+	b.fs.Var(value, name, field.Tag.Get("help"))
+	if v, err := GetPath(b.obj, path); err != nil || v.IsZero() {
+		// flag.PrintDefaults only leaves out defaults matching a zero fieldFlag
+		b.fs.Lookup(name).DefValue = ""
+	}
+	b.paths[name] = path
+	return nil
+}
+
+// isFlagContainer reports whether t is a slice, array or map of leaves, which
+// a single flag sets as a whole.
+func isFlagContainer(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return isLeafType(t.Elem())
+	case reflect.Map:
+		return isLeafType(t.Key()) && isLeafType(t.Elem())
+	}
+	return false
+}
+
+// fieldFlag is the flag.Value of a single field.
+type fieldFlag struct {
+	obj  interface{}
+	path string
+	typ  reflect.Type
+	set  func(path, s string) error
+}
+
+func (f *fieldFlag) String() string {
+	if f.obj == nil {
+		// the zero value flag.PrintDefaults compares defaults with
+		return ""
+	}
+	v, err := GetPath(f.obj, f.path)
+	if err != nil {
+		return ""
+	}
+	return DefaultStringCoercer.Format(v)
+}
+
+func (f *fieldFlag) Set(s string) error {
+	return f.set(f.path, s)
+}
+
+func (f *fieldFlag) IsBoolFlag() bool {
+	t := f.typ
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t != nil && t.Kind() == reflect.Bool
+}
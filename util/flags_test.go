@@ -0,0 +1,95 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type flagsDB struct {
+	DSN     string `help:"data source name"`
+	MaxConn int    `flag:"max"`
+}
+
+type flagsCommon struct {
+	Verbose bool
+}
+
+type flagsConfig struct {
+	flagsCommon
+	ListenPort int `help:"port to listen on"`
+	Timeout    time.Duration
+	Tags       []string
+	Labels     map[string]string
+	DB         *flagsDB
+	Secret     string `flag:"-"`
+	Next       *flagsConfig
+}
+
+func TestBindFlags(t *testing.T) {
+	Convey("When binding flags to a struct", t, func() {
+		cfg := flagsConfig{ListenPort: 80, Timeout: time.Second}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		So(BindFlags(fs, &cfg, ""), ShouldBeNil)
+
+		Convey("Flags should be named after their kebab case paths", func() {
+			var names []string
+			fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name+"="+f.DefValue) })
+			So(names, ShouldResemble, []string{
+				"db.dsn=", "db.max=", "labels=", "listen-port=80", "tags=", "timeout=1s", "verbose=",
+			})
+			So(fs.Lookup("listen-port").Usage, ShouldEqual, "port to listen on")
+		})
+		Convey("Parsed flags should be written into the struct", func() {
+			So(fs.Parse([]string{
+				"-verbose", "-listen-port", "8080", "-timeout=5s", "-tags=a,b", "-labels", "k=v", "-db.max=3",
+			}), ShouldBeNil)
+			So(cfg.Verbose, ShouldBeTrue)
+			So(cfg.ListenPort, ShouldEqual, 8080)
+			So(cfg.Timeout, ShouldEqual, 5*time.Second)
+			So(cfg.Tags, ShouldResemble, []string{"a", "b"})
+			So(cfg.Labels, ShouldResemble, map[string]string{"k": "v"})
+			So(cfg.DB, ShouldResemble, &flagsDB{MaxConn: 3})
+			So(cfg.Next, ShouldBeNil)
+		})
+		Convey("Unparseable values should fail parsing", func() {
+			So(fs.Parse([]string{"-timeout=soon"}), ShouldNotBeNil)
+		})
+	})
+
+	Convey("A prefix should qualify every flag name", t, func() {
+		cfg := flagsConfig{}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		So(BindFlags(fs, &cfg, "app"), ShouldBeNil)
+		So(fs.Lookup("app.db.dsn"), ShouldNotBeNil)
+		So(fs.Lookup("db.dsn"), ShouldBeNil)
+		So(fs.Parse([]string{"-app.db.dsn=postgres://"}), ShouldBeNil)
+		So(cfg.DB.DSN, ShouldEqual, "postgres://")
+		So(BindFlags(fs, &cfg, "app"), ShouldNotBeNil)
+	})
+}
+
+func TestStringCoercerFormat(t *testing.T) {
+	Convey("Formatted values should coerce back to the same value", t, func() {
+		for _, v := range []interface{}{
+			42,
+			"text",
+			90 * time.Second,
+			time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			[]int{1, 2},
+			map[string]int{"a": 1, "b": 2},
+		} {
+			s := DefaultStringCoercer.Format(reflect.ValueOf(v))
+			back, err := DefaultStringCoercer.Coerce(s, reflect.TypeOf(v))
+			So(err, ShouldBeNil)
+			So(back.Interface(), ShouldResemble, v)
+		}
+		So(DefaultStringCoercer.Format(reflect.ValueOf(map[string]int{"b": 2, "a": 1})), ShouldEqual, "a=1,b=2")
+		So(DefaultStringCoercer.Format(reflect.ValueOf((*int)(nil))), ShouldEqual, "")
+	})
+}
@@ -0,0 +1,91 @@
+package util
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// Flatten returns the leaf values of obj keyed by their path, using the
+// GetPath syntax, e.g. {"Server.Port": 8080, "Servers[0].Host": "a"}.
+// Leaves are scalars, nil pointers, empty slices and maps, byte slices and
+// types that marshal themselves to text, such as time.Time. Pointers to
+// leaves are dereferenced.
+func Flatten(obj interface{}) map[string]interface{} {
+	flat := map[string]interface{}{}
+	Walk(obj, func(path string, _ reflect.StructField, v reflect.Value) error {
+		if leaf, ok := flattenLeaf(v); ok {
+			flat[path] = leaf
+			return SkipSubtree
+		}
+		return nil
+	})
+	return flat
+}
+
+// Unflatten sets every entry of flat at its path within obj, which must be a
+// pointer. Like SetNestedStructIndex, nil pointers to structs are allocated on
+// the way; maps are created and slices grown as needed. Values are converted
+// the same way SetPath converts them.
+func Unflatten(flat map[string]interface{}, obj interface{}) error {
+	if _, err := settableRoot(obj); err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(flat))
+	for path := range flat {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if err := SetPath(obj, path, flat[path]); err != nil {
+			return fmt.Errorf("cannot unflatten %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// flattenLeaf returns the value to store for v if v is a leaf.
+func flattenLeaf(v reflect.Value) (interface{}, bool) {
+	if !v.IsValid() {
+		return nil, true
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, true
+		}
+		v = v.Elem()
+	}
+
+	if v.Type().Implements(textMarshalerType) || reflect.PtrTo(v.Type()).Implements(textMarshalerType) {
+		return v.Interface(), true
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if hasExportedFields(v.Type()) {
+			return nil, false
+		}
+	case reflect.Slice:
+		if v.Len() != 0 && v.Type().Elem().Kind() != reflect.Uint8 {
+			return nil, false
+		}
+	case reflect.Map, reflect.Array:
+		if v.Len() != 0 {
+			return nil, false
+		}
+	}
+	return v.Interface(), true
+}
+
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type flatConfig struct {
+	Name     string
+	Port     *int
+	Primary  *ServerConfig
+	Servers  []ServerConfig
+	Backups  []*ServerConfig
+	ByName   map[string]*ServerConfig
+	ByID     map[int]string
+	Matrix   [][]int
+	Optional *BStruct
+	Empty    []string
+}
+
+func getFlatConfig() *flatConfig {
+	port := 80
+	return &flatConfig{
+		Name: "app",
+		Port: &port,
+		Primary: &ServerConfig{
+			Host:    "primary",
+			Timeout: time.Second,
+			Started: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			IP:      net.ParseIP("10.0.0.1"),
+			Backup:  &ServerConfig{Host: "backup"},
+		},
+		Servers: []ServerConfig{{Host: "a", Tags: []string{"x", "y"}}, {Host: "b"}},
+		Backups: []*ServerConfig{{Host: "c", Labels: map[string]string{"env": "prod", "a.b": "c"}}},
+		ByName:  map[string]*ServerConfig{"web": {Port: 443}},
+		ByID:    map[int]string{7: "seven"},
+		Matrix:  [][]int{{1, 2}, {3}},
+		Empty:   []string{},
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	Convey("When calling Flatten", t, func() {
+		flat := Flatten(getFlatConfig())
+
+		Convey("Leaves should be keyed by their path", func() {
+			So(flat["Name"], ShouldEqual, "app")
+			So(flat["Primary.Backup.Host"], ShouldEqual, "backup")
+			So(flat["Servers[0].Tags[1]"], ShouldEqual, "y")
+			So(flat["Backups[0].Labels[env]"], ShouldEqual, "prod")
+			So(flat[`Backups[0].Labels["a.b"]`], ShouldEqual, "c")
+			So(flat["ByID[7]"], ShouldEqual, "seven")
+			So(flat["Matrix[1][0]"], ShouldEqual, 3)
+		})
+		Convey("Pointers to leaves should be dereferenced", func() {
+			So(flat["Port"], ShouldEqual, 80)
+		})
+		Convey("Nil pointers, empty slices and text marshalers should be leaves", func() {
+			So(flat, ShouldContainKey, "Optional")
+			So(flat["Optional"], ShouldBeNil)
+			So(flat["Empty"], ShouldResemble, []string{})
+			So(flat["Primary.Started"], ShouldResemble, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+			So(flat["Primary.IP"], ShouldResemble, net.ParseIP("10.0.0.1"))
+		})
+		Convey("Paths should be usable with NestedStructIndex", func() {
+			val, exists := NestedStructIndex("Primary.Backup.Host", reflect.ValueOf(getFlatConfig()))
+			So(exists, ShouldBeTrue)
+			So(val.Interface(), ShouldEqual, flat["Primary.Backup.Host"])
+		})
+	})
+}
+
+func TestUnflatten(t *testing.T) {
+	Convey("When calling Unflatten", t, func() {
+
+		Convey("Flattened values should round-trip", func() {
+			orig := getFlatConfig()
+			res := new(flatConfig)
+			So(Unflatten(Flatten(orig), res), ShouldBeNil)
+			So(res, ShouldResemble, orig)
+		})
+		Convey("Nil pointer structs should be allocated like SetNestedStructIndex does", func() {
+			res := new(AStruct)
+			So(Unflatten(map[string]interface{}{
+				"PtrNestedStruct.Exported":              "b",
+				"PtrNestedStruct.NestedStruct.Exported": "c",
+			}, res), ShouldBeNil)
+			So(res.PtrNestedStruct, ShouldResemble, &BStruct{Exported: "b", NestedStruct: &CStruct{"c"}})
+		})
+		Convey("Values decoded from JSON should be converted", func() {
+			var flat map[string]interface{}
+			So(json.Unmarshal([]byte(`{"Port": 8080, "Servers[1].Port": 9, "Primary.Timeout": "1s"}`), &flat), ShouldBeNil)
+			res := new(flatConfig)
+			So(Unflatten(flat, res), ShouldBeNil)
+			So(*res.Port, ShouldEqual, 8080)
+			So(res.Servers[1].Port, ShouldEqual, 9)
+			So(res.Primary.Timeout, ShouldEqual, time.Second)
+		})
+		Convey("Unknown paths should return an error", func() {
+			So(Unflatten(map[string]interface{}{"Nope": 1}, new(flatConfig)), ShouldNotBeNil)
+		})
+	})
+}
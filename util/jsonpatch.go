@@ -0,0 +1,397 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSONPatchOperation is a single RFC 6902 JSON Patch operation.
+type JSONPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch is an RFC 6902 JSON Patch document.
+type JSONPatch []JSONPatchOperation
+
+// ApplyJSONPatch applies the JSON Patch document patch directly to obj, which
+// must be a pointer. JSON Pointers are resolved onto struct fields through
+// their json tags, and onto slice indexes and map keys. Values are decoded
+// into the Go type at their target location, so type mismatches are errors.
+// The patch is atomic: if any operation fails, obj is left untouched, and
+// only the values it changes are written back, so other pointers, slices and
+// maps within obj keep their identity.
+func ApplyJSONPatch(obj interface{}, patch []byte) error {
+	var ops JSONPatch
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return fmt.Errorf("invalid json patch: %v", err)
+	}
+	return ops.Apply(obj)
+}
+
+// Apply applies p to obj atomically; see ApplyJSONPatch.
+func (p JSONPatch) Apply(obj interface{}) error {
+	root, err := settableRoot(obj)
+	if err != nil {
+		return err
+	}
+
+	// copy through the pointer so that cycles back to obj stay within the copy
+	working := deepCopyValue(root.Addr())
+	for i, op := range p {
+		if err := applyJSONPatchOperation(working.Elem(), op); err != nil {
+			return fmt.Errorf("json patch operation %d (%s %s): %v", i, op.Op, op.Path, err)
+		}
+	}
+	copyBackChanged(root.Addr(), working)
+	return nil
+}
+
+var jsonFields = fieldByTag("json")
+
+func applyJSONPatchOperation(root reflect.Value, op JSONPatchOperation) error {
+	path, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case "add":
+		if op.Value == nil {
+			return errors.New("missing value")
+		}
+		return patchSet(root, path, rawJSONDecoder(op.Value), true)
+	case "remove":
+		return patchRemove(root, path)
+	case "replace":
+		if op.Value == nil {
+			return errors.New("missing value")
+		}
+		if _, err := lookupPath(root, path, jsonFields); err != nil {
+			return err
+		}
+		return patchSet(root, path, rawJSONDecoder(op.Value), false)
+	case "move", "copy":
+		from, err := parseJSONPointer(op.From)
+		if err != nil {
+			return err
+		}
+		if op.Op == "move" && len(from) < len(path) && isPathPrefix(from, path) {
+			return fmt.Errorf("cannot move %q into its own child %q", op.From, op.Path)
+		}
+		src, err := lookupPath(root, from, jsonFields)
+		if err != nil {
+			return err
+		}
+		val := deepCopyValue(src)
+		if op.Op == "move" {
+			if err := patchRemove(root, from); err != nil {
+				return err
+			}
+		}
+		return patchSet(root, path, valueDecoder(val), true)
+	case "test":
+		if op.Value == nil {
+			return errors.New("missing value")
+		}
+		target, err := lookupPath(root, path, jsonFields)
+		if err != nil {
+			return err
+		}
+		expected, err := rawJSONDecoder(op.Value)(target.Type())
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(target.Interface(), expected.Interface()) {
+			return fmt.Errorf("test failed: value is %s", jsonString(target.Interface()))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown operation %q", op.Op)
+}
+
+var (
+	jsonPointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// parseJSONPointer splits an RFC 6901 JSON Pointer into unescaped path segments.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = jsonPointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+// formatJSONPointer is the inverse of parseJSONPointer.
+func formatJSONPointer(segs []string) string {
+	var buf strings.Builder
+	for _, seg := range segs {
+		buf.WriteByte('/')
+		buf.WriteString(jsonPointerEscaper.Replace(seg))
+	}
+	return buf.String()
+}
+
+func isPathPrefix(prefix, path []string) bool {
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// patchDecoder produces the value to store at a location of type t.
+type patchDecoder func(t reflect.Type) (reflect.Value, error)
+
+func rawJSONDecoder(raw json.RawMessage) patchDecoder {
+	return func(t reflect.Type) (reflect.Value, error) {
+		v := reflect.New(t)
+		if err := json.Unmarshal(raw, v.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		return v.Elem(), nil
+	}
+}
+
+func valueDecoder(val reflect.Value) patchDecoder {
+	return func(t reflect.Type) (reflect.Value, error) {
+		v := reflect.New(t).Elem()
+		if err := assignValue(v, val); err != nil {
+			return reflect.Value{}, err
+		}
+		return v, nil
+	}
+}
+
+// patchSet implements "add" and "replace": it sets struct fields and map
+// entries, and sets slice elements or, with insert, inserts them ("-"
+// appends). An empty path replaces the whole value.
+func patchSet(root reflect.Value, path []string, decode patchDecoder, insert bool) error {
+	if len(path) == 0 {
+		val, err := decode(root.Type())
+		if err != nil {
+			return err
+		}
+		root.Set(val)
+		return nil
+	}
+
+	parent, token := path[:len(path)-1], path[len(path)-1]
+	if _, err := lookupPath(root, parent, jsonFields); err != nil {
+		return err
+	}
+	return updatePath(root, parent, jsonFields, func(v reflect.Value) error {
+		return modifyContainer(v, func(c reflect.Value) error {
+			switch c.Kind() {
+			case reflect.Struct:
+				field, err := structField(c.Type(), token, jsonFields)
+				if err != nil {
+					return fmt.Errorf("%s: %w", token, err)
+				}
+				val, err := decode(field.Type)
+				if err != nil {
+					return err
+				}
+				fieldByIndexAlloc(c, field.Index).Set(val)
+			case reflect.Slice:
+				idx, max := c.Len(), c.Len()
+				if insert {
+					max++
+				}
+				if token != "-" || !insert {
+					var err error
+					if idx, err = strconv.Atoi(token); err != nil || idx < 0 || idx >= max {
+						return fmt.Errorf("invalid index %q for slice of len %d", token, c.Len())
+					}
+				}
+				val, err := decode(c.Type().Elem())
+				if err != nil {
+					return err
+				}
+				if !insert {
+					c.Index(idx).Set(val)
+					return nil
+				}
+				grown := reflect.MakeSlice(c.Type(), c.Len()+1, c.Len()+1)
+				reflect.Copy(grown, c.Slice(0, idx))
+				grown.Index(idx).Set(val)
+				reflect.Copy(grown.Slice(idx+1, grown.Len()), c.Slice(idx, c.Len()))
+				c.Set(grown)
+			case reflect.Array:
+				idx, err := strconv.Atoi(token)
+				if err != nil || idx < 0 || idx >= c.Len() {
+					return fmt.Errorf("invalid index %q for array of len %d", token, c.Len())
+				}
+				val, err := decode(c.Type().Elem())
+				if err != nil {
+					return err
+				}
+				c.Index(idx).Set(val)
+			case reflect.Map:
+				key, err := mapKey(c.Type().Key(), token)
+				if err != nil {
+					return err
+				}
+				val, err := decode(c.Type().Elem())
+				if err != nil {
+					return err
+				}
+				if c.IsNil() {
+					c.Set(reflect.MakeMap(c.Type()))
+				}
+				c.SetMapIndex(key, val)
+			default:
+				return fmt.Errorf("cannot add %q to %s", token, c.Kind())
+			}
+			return nil
+		})
+	})
+}
+
+// patchRemove implements "remove". Struct fields cannot be removed, so they
+// are reset to their zero value instead.
+func patchRemove(root reflect.Value, path []string) error {
+	if len(path) == 0 {
+		return errors.New("cannot remove the whole document")
+	}
+	if _, err := lookupPath(root, path, jsonFields); err != nil {
+		return err
+	}
+
+	parent, token := path[:len(path)-1], path[len(path)-1]
+	return updatePath(root, parent, jsonFields, func(v reflect.Value) error {
+		return modifyContainer(v, func(c reflect.Value) error {
+			switch c.Kind() {
+			case reflect.Struct:
+				field, _ := structField(c.Type(), token, jsonFields)
+				fieldByIndexAlloc(c, field.Index).Set(reflect.Zero(field.Type))
+			case reflect.Slice:
+				idx, _ := strconv.Atoi(token)
+				c.Set(reflect.ValueOf(SliceRemove(c.Interface(), idx)))
+			case reflect.Map:
+				key, _ := mapKey(c.Type().Key(), token)
+				c.SetMapIndex(key, reflect.Value{})
+			default:
+				return fmt.Errorf("cannot remove %q from %s", token, c.Kind())
+			}
+			return nil
+		})
+	})
+}
+
+// modifyContainer calls fn with the settable value behind v's pointers and
+// interfaces, storing the result back into v when it held an interface.
+func modifyContainer(v reflect.Value, fn func(reflect.Value) error) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return ErrPathNotFound
+		}
+		return modifyContainer(v.Elem(), fn)
+	case reflect.Interface:
+		if v.IsNil() {
+			return ErrPathNotFound
+		}
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		if err := modifyContainer(elem, fn); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	return fn(v)
+}
+
+func jsonString(x interface{}) string {
+	data, err := json.Marshal(x)
+	if err != nil {
+		return fmt.Sprint(x)
+	}
+	return string(data)
+}
+
+// copyBackChanged updates dst, the value working was deep-copied from, with
+// whatever differs in working. Values that did not change are left alone, so
+// pointers, slices and maps that a patch did not touch keep their identity.
+func copyBackChanged(dst, working reflect.Value) {
+	copyBack(dst, working, map[copyKey]bool{})
+}
+
+func copyBack(dst, src reflect.Value, seen map[copyKey]bool) {
+	// DeepCopy leaves locks zero in working, and they are not the patch's to reset
+	if dst.Type().PkgPath() == "sync" || reflect.DeepEqual(dst.Interface(), src.Interface()) {
+		return
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() || src.IsNil() {
+			break
+		}
+		key := copyKey{dst.Pointer(), dst.Type(), 0}
+		if !seen[key] {
+			seen[key] = true
+			copyBack(dst.Elem(), src.Elem(), seen)
+		}
+		return
+	case reflect.Struct:
+		if dst.Type() == timeType {
+			break
+		}
+		for i := 0; i < dst.NumField(); i++ {
+			// patches only reach exported fields, and those promoted from embedded structs
+			if field := dst.Type().Field(i); field.PkgPath == "" || field.Anonymous {
+				copyBack(settableField(dst, i), settableField(src, i), seen)
+			}
+		}
+		return
+	case reflect.Slice:
+		if dst.IsNil() || src.IsNil() || dst.Len() != src.Len() {
+			break
+		}
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < dst.Len(); i++ {
+			copyBack(dst.Index(i), src.Index(i), seen)
+		}
+		return
+	case reflect.Map:
+		if dst.IsNil() || src.IsNil() {
+			break
+		}
+		for _, key := range dst.MapKeys() {
+			if !src.MapIndex(key).IsValid() {
+				dst.SetMapIndex(key, reflect.Value{})
+			}
+		}
+		iter := src.MapRange()
+		for iter.Next() {
+			old := dst.MapIndex(iter.Key())
+			if old.IsValid() && old.Kind() == reflect.Ptr && !old.IsNil() && !iter.Value().IsNil() {
+				copyBack(old, iter.Value(), seen)
+				continue
+			}
+			if !old.IsValid() || !reflect.DeepEqual(old.Interface(), iter.Value().Interface()) {
+				dst.SetMapIndex(iter.Key(), iter.Value())
+			}
+		}
+		return
+	}
+	dst.Set(src)
+}
@@ -0,0 +1,124 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"testing"
+)
+
+type patchEndpoint struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+type patchBase struct {
+	Version int `json:"version"`
+}
+
+type patchConfig struct {
+	patchBase
+	Name      string                    `json:"name"`
+	Endpoints []patchEndpoint           `json:"endpoints"`
+	Labels    map[string]string         `json:"labels,omitempty"`
+	Primary   *patchEndpoint            `json:"primary,omitempty"`
+	Extra     map[string]interface{}    `json:"extra,omitempty"`
+	ByName    map[string]*patchEndpoint `json:"by_name,omitempty"`
+	internal  string
+}
+
+type patchNode struct {
+	Name string     `json:"name"`
+	Next *patchNode `json:"next,omitempty"`
+}
+
+func getPatchConfig() *patchConfig {
+	return &patchConfig{
+		patchBase: patchBase{1},
+		Name:      "app",
+		Endpoints: []patchEndpoint{{"a", 1}, {"b", 2}},
+		Labels:    map[string]string{"env": "dev"},
+		Extra:     map[string]interface{}{"list": []interface{}{"x"}},
+		internal:  "kept",
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	Convey("When calling ApplyJSONPatch", t, func() {
+		cfg := getPatchConfig()
+
+		Convey("add should set fields, insert into slices and set map keys", func() {
+			So(ApplyJSONPatch(cfg, []byte(`[
+				{"op": "add", "path": "/name", "value": "svc"},
+				{"op": "add", "path": "/version", "value": 2},
+				{"op": "add", "path": "/endpoints/1", "value": {"host": "mid", "port": 9}},
+				{"op": "add", "path": "/endpoints/-", "value": {"host": "end"}},
+				{"op": "add", "path": "/labels/team~1owner", "value": "core"},
+				{"op": "add", "path": "/primary", "value": {"host": "p"}},
+				{"op": "add", "path": "/extra/list/0", "value": "w"}
+			]`)), ShouldBeNil)
+			So(cfg.Name, ShouldEqual, "svc")
+			So(cfg.Version, ShouldEqual, 2)
+			So(cfg.Endpoints, ShouldResemble, []patchEndpoint{{"a", 1}, {"mid", 9}, {"b", 2}, {"end", 0}})
+			So(cfg.Labels, ShouldResemble, map[string]string{"env": "dev", "team/owner": "core"})
+			So(cfg.Primary, ShouldResemble, &patchEndpoint{Host: "p"})
+			So(cfg.Extra["list"], ShouldResemble, []interface{}{"w", "x"})
+			So(cfg.internal, ShouldEqual, "kept")
+		})
+		Convey("remove and replace should require an existing target", func() {
+			So(ApplyJSONPatch(cfg, []byte(`[
+				{"op": "remove", "path": "/endpoints/0"},
+				{"op": "remove", "path": "/labels/env"},
+				{"op": "replace", "path": "/endpoints/0/port", "value": 3}
+			]`)), ShouldBeNil)
+			So(cfg.Endpoints, ShouldResemble, []patchEndpoint{{"b", 3}})
+			So(cfg.Labels, ShouldBeEmpty)
+
+			So(ApplyJSONPatch(cfg, []byte(`[{"op": "remove", "path": "/labels/missing"}]`)), ShouldNotBeNil)
+			So(ApplyJSONPatch(cfg, []byte(`[{"op": "replace", "path": "/endpoints/5", "value": {}}]`)), ShouldNotBeNil)
+		})
+		Convey("move and copy should convert between locations", func() {
+			So(ApplyJSONPatch(cfg, []byte(`[
+				{"op": "copy", "from": "/endpoints/0", "path": "/primary"},
+				{"op": "move", "from": "/endpoints/1/host", "path": "/labels/moved"}
+			]`)), ShouldBeNil)
+			So(cfg.Primary, ShouldResemble, &patchEndpoint{"a", 1})
+			So(cfg.Labels["moved"], ShouldEqual, "b")
+			So(cfg.Endpoints[1].Host, ShouldEqual, "")
+		})
+		Convey("test should compare typed values", func() {
+			So(ApplyJSONPatch(cfg, []byte(`[{"op": "test", "path": "/endpoints/1", "value": {"host": "b", "port": 2}}]`)), ShouldBeNil)
+			So(ApplyJSONPatch(cfg, []byte(`[{"op": "test", "path": "/name", "value": "other"}]`)), ShouldNotBeNil)
+		})
+		Convey("A failed operation should leave the object untouched", func() {
+			err := ApplyJSONPatch(cfg, []byte(`[
+				{"op": "replace", "path": "/name", "value": "changed"},
+				{"op": "add", "path": "/labels/x", "value": "y"},
+				{"op": "replace", "path": "/endpoints/0/port", "value": "not a number"}
+			]`))
+			So(err, ShouldNotBeNil)
+			So(cfg, ShouldResemble, getPatchConfig())
+		})
+		Convey("Values the patch does not touch should keep their identity", func() {
+			cfg.Primary = &patchEndpoint{Host: "p"}
+			primary, labels := cfg.Primary, cfg.Labels
+			So(ApplyJSONPatch(cfg, []byte(`[
+				{"op": "replace", "path": "/name", "value": "svc"},
+				{"op": "add", "path": "/labels/team", "value": "core"}
+			]`)), ShouldBeNil)
+			So(cfg.Primary, ShouldPointTo, primary)
+			So(labels["team"], ShouldEqual, "core")
+		})
+		Convey("Cyclic values should be patched", func() {
+			node := &patchNode{Name: "a"}
+			node.Next = node
+			So(ApplyJSONPatch(node, []byte(`[{"op": "replace", "path": "/next/name", "value": "b"}]`)), ShouldBeNil)
+			So(node.Name, ShouldEqual, "b")
+			So(node.Next, ShouldPointTo, node)
+		})
+		Convey("Unknown fields and operations should be errors", func() {
+			So(ApplyJSONPatch(cfg, []byte(`[{"op": "add", "path": "/nope", "value": 1}]`)), ShouldNotBeNil)
+			So(ApplyJSONPatch(cfg, []byte(`[{"op": "frobnicate", "path": "/name"}]`)), ShouldNotBeNil)
+			So(ApplyJSONPatch(cfg, []byte(`[{"op": "add", "path": "/internal", "value": "x"}]`)), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,264 @@
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+type MergeStrategy int
+
+const (
+	// MergeOverwriteNonZero replaces the destination unless the source is a
+	// zero value. It is the default.
+	MergeOverwriteNonZero MergeStrategy = iota
+	// MergeOverwrite always replaces the destination, even with zero values.
+	MergeOverwrite
+	// MergeKeepExisting only sets the destination if it is a zero value.
+	MergeKeepExisting
+	// MergeAppend appends source slices to destination slices.
+	MergeAppend
+	// MergeUnion appends the elements of source slices that the destination
+	// does not contain yet.
+	MergeUnion
+	// MergeDeep merges maps and pointers entry by entry instead of replacing them.
+	MergeDeep
+)
+
+var mergeStrategyNames = map[string]MergeStrategy{
+	"nonzero":   MergeOverwriteNonZero,
+	"overwrite": MergeOverwrite,
+	"keep":      MergeKeepExisting,
+	"append":    MergeAppend,
+	"union":     MergeUnion,
+	"deep":      MergeDeep,
+}
+
+func (s MergeStrategy) String() string {
+	for name, strategy := range mergeStrategyNames {
+		if strategy == s {
+			return name
+		}
+	}
+	return fmt.Sprintf("MergeStrategy(%d)", int(s))
+}
+
+// ParseMergeStrategy returns the strategy named by a `merge` struct tag:
+// "nonzero", "overwrite", "keep", "append", "union" or "deep".
+func ParseMergeStrategy(name string) (MergeStrategy, error) {
+	if s, ok := mergeStrategyNames[name]; ok {
+		return s, nil
+	}
+	return 0, fmt.Errorf("unknown merge strategy %q", name)
+}
+
+type MergeOptions struct {
+	// Strategy applies to every value without a strategy of its own.
+	Strategy MergeStrategy
+	// Paths selects strategies by QueryPaths-style pattern, e.g.
+	// {"Servers": MergeAppend, "Labels": MergeDeep}. They take precedence
+	// over `merge` struct tags. Where patterns overlap, the most specific
+	// wins: the one with the most literal segments, then the fewest "**".
+	Paths map[string]MergeStrategy
+}
+
+// Merge merges src into dst, which must be a pointer to the type of src (or
+// of *src). Structs, and pointers to them, are merged field by field; every
+// other value is merged with its strategy, which comes from MergeOptions.Paths,
+// then from a `merge:"<strategy>"` tag on its field, then from
+// MergeOptions.Strategy. Selecting MergeOverwrite, MergeKeepExisting or
+// MergeOverwriteNonZero for a struct through a path or tag treats the struct
+// as a single value. Values taken from src are deep copies.
+func Merge(dst, src interface{}, opts MergeOptions) error {
+	root, err := settableRoot(dst)
+	if err != nil {
+		return err
+	}
+	srcVal := reflect.ValueOf(src)
+	if srcVal.Kind() == reflect.Ptr && srcVal.Type() == reflect.PtrTo(root.Type()) {
+		if srcVal.IsNil() {
+			return nil
+		}
+		srcVal = srcVal.Elem()
+	}
+	if srcVal.Type() != root.Type() {
+		return fmt.Errorf("cannot merge %s into %s", srcVal.Type(), root.Type())
+	}
+
+	m := &merger{opts: opts}
+	for pattern, strategy := range opts.Paths {
+		segs, err := splitPath(pattern)
+		if err != nil {
+			return err
+		}
+		m.paths = append(m.paths, mergePath{pattern, segs, strategy})
+	}
+	sort.Slice(m.paths, func(i, j int) bool { return m.paths[i].moreSpecific(m.paths[j]) })
+	return m.merge("", root, srcVal, reflect.StructField{})
+}
+
+type merger struct {
+	opts MergeOptions
+	// most specific first
+	paths []mergePath
+}
+
+type mergePath struct {
+	pattern  string
+	segs     []string
+	strategy MergeStrategy
+}
+
+func (p mergePath) moreSpecific(q mergePath) bool {
+	pl, pd := wildcardCounts(p.segs)
+	ql, qd := wildcardCounts(q.segs)
+	switch {
+	case pl != ql:
+		return pl > ql
+	case pd != qd:
+		return pd < qd
+	case len(p.segs) != len(q.segs):
+		return len(p.segs) > len(q.segs)
+	}
+	return p.pattern < q.pattern
+}
+
+// wildcardCounts returns the number of literal and "**" segments in segs.
+func wildcardCounts(segs []string) (literals, doubles int) {
+	for _, seg := range segs {
+		switch seg {
+		case "**":
+			doubles++
+		case "*":
+		default:
+			literals++
+		}
+	}
+	return literals, doubles
+}
+
+// strategy returns the strategy for the value at path held by field, and
+// whether it was selected explicitly by a path or tag.
+func (m *merger) strategy(path string, field reflect.StructField) (MergeStrategy, bool, error) {
+	if len(m.paths) > 0 {
+		segs, _ := splitPath(path)
+		for _, p := range m.paths {
+			if pathMatches(p.segs, segs) {
+				return p.strategy, true, nil
+			}
+		}
+	}
+	if tag, ok := field.Tag.Lookup("merge"); ok {
+		s, err := ParseMergeStrategy(tag)
+		return s, true, err
+	}
+	return m.opts.Strategy, false, nil
+}
+
+func (m *merger) merge(path string, dst, src reflect.Value, field reflect.StructField) error {
+	s, explicit, err := m.strategy(path, field)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	wholeValue := s == MergeOverwrite || s == MergeKeepExisting || s == MergeOverwriteNonZero
+	if isStructOrPtrToStruct(src.Type()) && !(explicit && wholeValue) {
+		return m.mergeStruct(path, dst, src)
+	}
+
+	switch s {
+	case MergeDeep:
+		switch src.Kind() {
+		case reflect.Ptr:
+			if src.IsNil() {
+				return nil
+			}
+			if dst.IsNil() {
+				dst.Set(deepCopyValue(src))
+				return nil
+			}
+			return m.merge(path, dst.Elem(), src.Elem(), reflect.StructField{})
+		case reflect.Map:
+			return m.mergeMap(path, dst, src)
+		}
+	case MergeAppend, MergeUnion:
+		if src.Kind() == reflect.Slice {
+			merged := dst
+			for i := 0; i < src.Len(); i++ {
+				elem := src.Index(i)
+				if s == MergeUnion && merged.IsValid() && SliceContains(merged.Interface(), elem.Interface()) {
+					continue
+				}
+				merged = reflect.Append(merged, deepCopyValue(elem))
+			}
+			dst.Set(merged)
+			return nil
+		}
+	case MergeOverwrite:
+		dst.Set(deepCopyValue(src))
+		return nil
+	case MergeKeepExisting:
+		if dst.IsZero() {
+			dst.Set(deepCopyValue(src))
+		}
+		return nil
+	}
+
+	// MergeOverwriteNonZero, and the fallback for container strategies on other kinds
+	if !src.IsZero() {
+		dst.Set(deepCopyValue(src))
+	}
+	return nil
+}
+
+func (m *merger) mergeStruct(path string, dst, src reflect.Value) error {
+	if src.Kind() == reflect.Ptr {
+		if src.IsNil() {
+			return nil
+		}
+		if dst.IsNil() {
+			dst.Set(deepCopyValue(src))
+			return nil
+		}
+		dst, src = dst.Elem(), src.Elem()
+	}
+
+	t := src.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if err := m.merge(joinField(path, field.Name), dst.Field(i), src.Field(i), field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *merger) mergeMap(path string, dst, src reflect.Value) error {
+	if src.IsNil() {
+		return nil
+	}
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMapWithSize(dst.Type(), src.Len()))
+	}
+	for _, key := range sortedMapKeys(src) {
+		elem := reflect.New(dst.Type().Elem()).Elem()
+		if cur := dst.MapIndex(key); cur.IsValid() {
+			elem.Set(cur)
+		}
+		if err := m.merge(joinKey(path, key.Interface()), elem, src.MapIndex(key), reflect.StructField{}); err != nil {
+			return err
+		}
+		dst.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+func isStructOrPtrToStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && hasExportedFields(t)
+}
@@ -0,0 +1,115 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"testing"
+)
+
+type mergeServer struct {
+	Host string
+	Port int
+}
+
+type mergeConfig struct {
+	Name     string
+	Debug    bool
+	Primary  *mergeServer
+	Server   mergeServer
+	Fixed    mergeServer `merge:"overwrite"`
+	Tags     []string    `merge:"union"`
+	Plugins  []string    `merge:"append"`
+	Hosts    []string
+	Labels   map[string]string `merge:"deep"`
+	Limits   map[string]int
+	Owner    string `merge:"keep"`
+	Override string `merge:"overwrite"`
+}
+
+func TestMerge(t *testing.T) {
+	Convey("When calling Merge", t, func() {
+		dst := &mergeConfig{
+			Name:     "defaults",
+			Debug:    true,
+			Server:   mergeServer{"localhost", 80},
+			Fixed:    mergeServer{"fixed", 1},
+			Tags:     []string{"a", "b"},
+			Plugins:  []string{"p1"},
+			Hosts:    []string{"h1"},
+			Labels:   map[string]string{"env": "dev", "team": "core"},
+			Limits:   map[string]int{"cpu": 1},
+			Owner:    "me",
+			Override: "old",
+		}
+		src := &mergeConfig{
+			Server:  mergeServer{Port: 8080},
+			Primary: &mergeServer{Host: "p"},
+			Fixed:   mergeServer{Host: "other"},
+			Tags:    []string{"b", "c"},
+			Plugins: []string{"p1", "p2"},
+			Hosts:   []string{"h2"},
+			Labels:  map[string]string{"env": "prod"},
+			Limits:  map[string]int{"mem": 2},
+			Owner:   "you",
+		}
+
+		Convey("Tags should select the strategy for each field", func() {
+			So(Merge(dst, src, MergeOptions{}), ShouldBeNil)
+			So(dst.Name, ShouldEqual, "defaults")
+			So(dst.Debug, ShouldBeTrue)
+			So(dst.Server, ShouldResemble, mergeServer{"localhost", 8080})
+			So(dst.Primary, ShouldResemble, &mergeServer{Host: "p"})
+			So(dst.Primary, ShouldNotPointTo, src.Primary)
+			So(dst.Fixed, ShouldResemble, mergeServer{Host: "other"})
+			So(dst.Tags, ShouldResemble, []string{"a", "b", "c"})
+			So(dst.Plugins, ShouldResemble, []string{"p1", "p1", "p2"})
+			So(dst.Hosts, ShouldResemble, []string{"h2"})
+			So(dst.Labels, ShouldResemble, map[string]string{"env": "prod", "team": "core"})
+			So(dst.Limits, ShouldResemble, map[string]int{"mem": 2})
+			So(dst.Owner, ShouldEqual, "me")
+			So(dst.Override, ShouldEqual, "")
+		})
+		Convey("Path strategies should take precedence over tags", func() {
+			So(Merge(dst, src, MergeOptions{Paths: map[string]MergeStrategy{
+				"Limits":   MergeDeep,
+				"Hosts":    MergeAppend,
+				"Override": MergeKeepExisting,
+				"Server":   MergeOverwrite,
+			}}), ShouldBeNil)
+			So(dst.Limits, ShouldResemble, map[string]int{"cpu": 1, "mem": 2})
+			So(dst.Hosts, ShouldResemble, []string{"h1", "h2"})
+			So(dst.Override, ShouldEqual, "old")
+			So(dst.Server, ShouldResemble, mergeServer{Port: 8080})
+		})
+		Convey("Overlapping path patterns should resolve to the most specific", func() {
+			for i := 0; i < 20; i++ {
+				a := struct{ Tags, Hosts []string }{[]string{"a"}, []string{"h1"}}
+				b := struct{ Tags, Hosts []string }{[]string{"b"}, []string{"h2"}}
+				So(Merge(&a, b, MergeOptions{Paths: map[string]MergeStrategy{
+					"Tags": MergeAppend,
+					"*":    MergeOverwrite,
+				}}), ShouldBeNil)
+				So(a.Tags, ShouldResemble, []string{"a", "b"})
+				So(a.Hosts, ShouldResemble, []string{"h2"})
+			}
+		})
+		Convey("The default strategy should apply to untagged values", func() {
+			So(Merge(dst, *src, MergeOptions{Strategy: MergeOverwrite}), ShouldBeNil)
+			So(dst.Name, ShouldEqual, "")
+			So(dst.Server, ShouldResemble, mergeServer{"", 8080})
+		})
+		Convey("Maps should merge recursively", func() {
+			a := map[string]map[string]int{"x": {"a": 1}}
+			b := map[string]map[string]int{"x": {"b": 2}, "y": {"c": 3}}
+			So(Merge(&a, b, MergeOptions{Strategy: MergeDeep}), ShouldBeNil)
+			So(a, ShouldResemble, map[string]map[string]int{"x": {"a": 1, "b": 2}, "y": {"c": 3}})
+		})
+		Convey("Mismatched types and bad tags should be errors", func() {
+			So(Merge(dst, "string", MergeOptions{}), ShouldNotBeNil)
+			bad := &struct {
+				X int `merge:"sideways"`
+			}{}
+			So(Merge(bad, bad, MergeOptions{}), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,314 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// ApplyMergePatch applies the RFC 7386 JSON Merge Patch doc to obj, which must
+// be a pointer. Object members are matched to struct fields through their json
+// tags and to map keys; null members reset fields to their zero value and
+// delete map keys. Nil pointers to structs and nil maps are allocated when
+// the patch has members for them. Like ApplyJSONPatch, a failed patch leaves
+// obj untouched.
+func ApplyMergePatch(obj interface{}, doc []byte) error {
+	root, err := settableRoot(obj)
+	if err != nil {
+		return err
+	}
+	if !json.Valid(doc) {
+		return fmt.Errorf("invalid json merge patch")
+	}
+
+	working := deepCopyValue(root.Addr())
+	if err := applyMergePatch(working.Elem(), doc); err != nil {
+		return err
+	}
+	copyBackChanged(root.Addr(), working)
+	return nil
+}
+
+func applyMergePatch(v reflect.Value, doc json.RawMessage) error {
+	if isJSONNull(doc) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	var members map[string]json.RawMessage
+	if !isJSONObject(doc) || json.Unmarshal(doc, &members) != nil {
+		return decodeJSONInto(v, doc)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return applyMergePatch(v.Elem(), doc)
+	case reflect.Interface:
+		var current interface{}
+		if !v.IsNil() {
+			data, err := json.Marshal(v.Interface())
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(data, &current); err != nil {
+				return err
+			}
+		}
+		var patch interface{}
+		if err := json.Unmarshal(doc, &patch); err != nil {
+			return err
+		}
+		merged := reflect.ValueOf(mergeGeneric(current, patch))
+		if !merged.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("cannot merge an object into %s", v.Type())
+		}
+		v.Set(merged)
+		return nil
+	case reflect.Struct:
+		for _, name := range sortedJSONKeys(members) {
+			field, err := structField(v.Type(), name, jsonFields)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if err := applyMergePatch(fieldByIndexAlloc(v, field.Index), members[name]); err != nil {
+				return wrapSegment(name, err)
+			}
+		}
+		return nil
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for _, name := range sortedJSONKeys(members) {
+			key, err := mapKey(v.Type().Key(), name)
+			if err != nil {
+				return err
+			}
+			if isJSONNull(members[name]) {
+				v.SetMapIndex(key, reflect.Value{})
+				continue
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if cur := v.MapIndex(key); cur.IsValid() {
+				elem.Set(cur)
+			}
+			if err := applyMergePatch(elem, members[name]); err != nil {
+				return wrapSegment(name, err)
+			}
+			v.SetMapIndex(key, elem)
+		}
+		return nil
+	}
+	return decodeJSONInto(v, doc)
+}
+
+// mergeGeneric applies a merge patch to a generic JSON value (RFC 7386 section 2).
+func mergeGeneric(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for key, val := range patchObj {
+		if val == nil {
+			delete(targetObj, key)
+		} else {
+			targetObj[key] = mergeGeneric(targetObj[key], val)
+		}
+	}
+	return targetObj
+}
+
+// CreateMergePatch returns the JSON Merge Patch that turns old into new, as
+// seen through their JSON encoding. As RFC 7386 uses null for deletion, a
+// null nested inside a newly added object cannot be expressed and is dropped
+// when the patch is applied.
+func CreateMergePatch(old, new interface{}) ([]byte, error) {
+	a, b, err := toGenericJSONPair(old, new)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(createMergePatch(a, b))
+}
+
+func createMergePatch(a, b interface{}) interface{} {
+	aObj, aOK := a.(map[string]interface{})
+	bObj, bOK := b.(map[string]interface{})
+	if !aOK || !bOK {
+		return b
+	}
+
+	patch := map[string]interface{}{}
+	for key := range aObj {
+		if _, ok := bObj[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	for key, bVal := range bObj {
+		aVal, ok := aObj[key]
+		switch {
+		case !ok:
+			patch[key] = bVal
+		case reflect.DeepEqual(aVal, bVal):
+		default:
+			patch[key] = createMergePatch(aVal, bVal)
+		}
+	}
+	return patch
+}
+
+// CreateJSONPatch returns a JSON Patch that turns old into new, as seen
+// through their JSON encoding. The patch can be applied with ApplyJSONPatch.
+func CreateJSONPatch(old, new interface{}) ([]byte, error) {
+	a, b, err := toGenericJSONPair(old, new)
+	if err != nil {
+		return nil, err
+	}
+	patch := JSONPatch{}
+	if err := createJSONPatch(nil, a, b, &patch); err != nil {
+		return nil, err
+	}
+	return json.Marshal(patch)
+}
+
+func createJSONPatch(path []string, a, b interface{}, patch *JSONPatch) error {
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+
+	child := func(seg string) []string {
+		return append(append([]string{}, path...), seg)
+	}
+	addOp := func(op string, path []string, x interface{}) error {
+		entry := JSONPatchOperation{Op: op, Path: formatJSONPointer(path)}
+		if op != "remove" {
+			raw, err := json.Marshal(x)
+			if err != nil {
+				return err
+			}
+			entry.Value = raw
+		}
+		*patch = append(*patch, entry)
+		return nil
+	}
+
+	switch aVal := a.(type) {
+	case map[string]interface{}:
+		bVal, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, key := range sortedGenericKeys(aVal) {
+			if _, ok := bVal[key]; !ok {
+				if err := addOp("remove", child(key), nil); err != nil {
+					return err
+				}
+			}
+		}
+		for _, key := range sortedGenericKeys(bVal) {
+			var err error
+			if old, ok := aVal[key]; ok {
+				err = createJSONPatch(child(key), old, bVal[key], patch)
+			} else {
+				err = addOp("add", child(key), bVal[key])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		bVal, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(aVal) && i < len(bVal); i++ {
+			if err := createJSONPatch(child(strconv.Itoa(i)), aVal[i], bVal[i], patch); err != nil {
+				return err
+			}
+		}
+		for i := len(aVal); i < len(bVal); i++ {
+			if err := addOp("add", child("-"), bVal[i]); err != nil {
+				return err
+			}
+		}
+		// remove from the end so earlier indexes stay valid
+		for i := len(aVal) - 1; i >= len(bVal); i-- {
+			if err := addOp("remove", child(strconv.Itoa(i)), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return addOp("replace", path, b)
+}
+
+func toGenericJSONPair(a, b interface{}) (interface{}, interface{}, error) {
+	genericA, err := toGenericJSON(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	genericB, err := toGenericJSON(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return genericA, genericB, nil
+}
+
+// toGenericJSON round-trips x through encoding/json into maps, slices and scalars.
+func toGenericJSON(x interface{}) (interface{}, error) {
+	data, err := json.Marshal(x)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+func decodeJSONInto(v reflect.Value, doc json.RawMessage) error {
+	decoded := reflect.New(v.Type())
+	if err := json.Unmarshal(doc, decoded.Interface()); err != nil {
+		return err
+	}
+	v.Set(decoded.Elem())
+	return nil
+}
+
+func isJSONNull(doc json.RawMessage) bool {
+	return string(bytes.TrimSpace(doc)) == "null"
+}
+
+func isJSONObject(doc json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(doc)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+func sortedJSONKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedGenericKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,103 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"encoding/json"
+	"testing"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	Convey("When calling ApplyMergePatch", t, func() {
+		cfg := getPatchConfig()
+
+		Convey("Members should be merged through json tags", func() {
+			So(ApplyMergePatch(cfg, []byte(`{
+				"name": "svc",
+				"labels": {"env": null, "team": "core"},
+				"primary": {"port": 443},
+				"by_name": {"web": {"host": "w"}},
+				"extra": {"list": null, "n": 1}
+			}`)), ShouldBeNil)
+			So(cfg.Name, ShouldEqual, "svc")
+			So(cfg.Labels, ShouldResemble, map[string]string{"team": "core"})
+			So(cfg.Primary, ShouldResemble, &patchEndpoint{Port: 443})
+			So(cfg.ByName["web"], ShouldResemble, &patchEndpoint{Host: "w"})
+			So(cfg.Extra, ShouldResemble, map[string]interface{}{"n": 1.0})
+			So(cfg.Endpoints, ShouldResemble, getPatchConfig().Endpoints)
+		})
+		Convey("Arrays and null members should replace values", func() {
+			So(ApplyMergePatch(cfg, []byte(`{"endpoints": [{"host": "z"}], "name": null}`)), ShouldBeNil)
+			So(cfg.Endpoints, ShouldResemble, []patchEndpoint{{Host: "z"}})
+			So(cfg.Name, ShouldEqual, "")
+		})
+		Convey("Cyclic values should be patched and untouched pointers kept", func() {
+			node := &patchNode{Name: "a"}
+			node.Next = node
+			So(ApplyMergePatch(node, []byte(`{"name": "b"}`)), ShouldBeNil)
+			So(node.Name, ShouldEqual, "b")
+			So(node.Next, ShouldPointTo, node)
+		})
+		Convey("A failed patch should leave the object untouched", func() {
+			So(ApplyMergePatch(cfg, []byte(`{"name": "x", "version": "two"}`)), ShouldNotBeNil)
+			So(ApplyMergePatch(cfg, []byte(`{"name": "x", "unknown": 1}`)), ShouldNotBeNil)
+			So(cfg, ShouldResemble, getPatchConfig())
+		})
+	})
+}
+
+func TestCreatePatches(t *testing.T) {
+	Convey("When creating patches between two structs", t, func() {
+		old := getPatchConfig()
+		updated := getPatchConfig()
+		updated.Name = "svc"
+		updated.Version = 2
+		updated.Labels = map[string]string{"team": "a/b"}
+		updated.Endpoints = append(updated.Endpoints, patchEndpoint{"c", 3})
+		updated.Endpoints[0].Port = 10
+		updated.Primary = &patchEndpoint{Host: "p"}
+
+		Convey("CreateMergePatch should only contain the differences", func() {
+			patch, err := CreateMergePatch(old, updated)
+			So(err, ShouldBeNil)
+			var generic map[string]interface{}
+			So(json.Unmarshal(patch, &generic), ShouldBeNil)
+			So(generic, ShouldContainKey, "endpoints")
+			So(generic["labels"], ShouldResemble, map[string]interface{}{"env": nil, "team": "a/b"})
+			So(generic, ShouldNotContainKey, "extra")
+
+			So(ApplyMergePatch(old, patch), ShouldBeNil)
+			So(old, ShouldResemble, updated)
+		})
+		Convey("CreateJSONPatch should produce minimal operations", func() {
+			patch, err := CreateJSONPatch(old, updated)
+			So(err, ShouldBeNil)
+			var ops JSONPatch
+			So(json.Unmarshal(patch, &ops), ShouldBeNil)
+			paths := []string{}
+			for _, op := range ops {
+				paths = append(paths, op.Op+" "+op.Path)
+			}
+			So(paths, ShouldResemble, []string{
+				"replace /endpoints/0/port",
+				"add /endpoints/-",
+				"remove /labels/env",
+				"add /labels/team",
+				"replace /name",
+				"add /primary",
+				"replace /version",
+			})
+
+			So(ApplyJSONPatch(old, patch), ShouldBeNil)
+			So(old, ShouldResemble, updated)
+		})
+		Convey("Identical values should produce empty patches", func() {
+			patch, err := CreateJSONPatch(old, getPatchConfig())
+			So(err, ShouldBeNil)
+			So(string(patch), ShouldEqual, "[]")
+			patch, err = CreateMergePatch(old, getPatchConfig())
+			So(err, ShouldBeNil)
+			So(string(patch), ShouldEqual, "{}")
+		})
+	})
+}
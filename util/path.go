@@ -0,0 +1,343 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrPathNotFound is returned (wrapped) when a path does not resolve to a value.
+var ErrPathNotFound = errors.New("path not found")
+
+// fieldLookup finds the struct field of t addressed by a single path segment.
+type fieldLookup func(t reflect.Type, name string) (reflect.StructField, bool)
+
+// fieldByGoName looks fields up by their Go name, the same way NestedStructIndex does.
+func fieldByGoName(t reflect.Type, name string) (reflect.StructField, bool) {
+	return t.FieldByName(name)
+}
+
+// GetPath returns the value at path within obj. Paths use the NestedStructIndex
+// dotted syntax extended with slice indexes and map keys, e.g.
+// "Servers[0].Host" or "Labels[env]". Keys containing dots or brackets can be
+// quoted: Labels["app.kubernetes.io/name"].
+func GetPath(obj interface{}, path string) (reflect.Value, error) {
+	segs, err := splitPath(path)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return lookupPath(reflect.ValueOf(obj), segs, fieldByGoName)
+}
+
+// SetPath sets the value at path within obj, which must be a pointer. Nil
+// pointers and maps along the way are allocated, and slices are grown to fit
+// the index being set. x is converted to the target type where possible;
+// strings are parsed with DefaultStringCoercer.
+func SetPath(obj interface{}, path string, x interface{}) error {
+	segs, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	root, err := settableRoot(obj)
+	if err != nil {
+		return err
+	}
+	return updatePath(root, segs, fieldByGoName, func(dst reflect.Value) error {
+		return assignValue(dst, reflect.ValueOf(x))
+	})
+}
+
+// splitPath breaks a path such as `Servers[0].Labels["a.b"]` into its
+// segments ("Servers", "0", "Labels", "a.b").
+func splitPath(path string) ([]string, error) {
+	// sanitize the key input
+	path = strings.TrimSuffix(path, ".")
+
+	var segs []string
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '[':
+			if i+1 < len(path) && path[i+1] == '"' {
+				quoted, err := strconv.QuotedPrefix(path[i+1:])
+				if err != nil {
+					return nil, fmt.Errorf("invalid quoted key in path %q: %v", path, err)
+				}
+				end := i + 1 + len(quoted)
+				if end >= len(path) || path[end] != ']' {
+					return nil, fmt.Errorf("missing ']' in path %q", path)
+				}
+				key, _ := strconv.Unquote(quoted)
+				segs = append(segs, key)
+				i = end + 1
+				continue
+			}
+			end := strings.IndexByte(path[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("missing ']' in path %q", path)
+			}
+			segs = append(segs, path[i+1:i+end])
+			i += end + 1
+		case '.':
+			if i == 0 || i+1 == len(path) || path[i+1] == '.' || path[i+1] == '[' {
+				return nil, fmt.Errorf("empty field name in path %q", path)
+			}
+			i++
+		default:
+			if i > 0 && path[i-1] == ']' {
+				return nil, fmt.Errorf("missing '.' after ']' in path %q", path)
+			}
+			end := strings.IndexAny(path[i:], ".[")
+			if end == -1 {
+				end = len(path) - i
+			}
+			segs = append(segs, path[i:i+end])
+			i += end
+		}
+	}
+	return segs, nil
+}
+
+// joinField appends a struct field name to path.
+func joinField(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// joinIndex appends a slice or array index to path.
+func joinIndex(path string, idx int) string {
+	return path + "[" + strconv.Itoa(idx) + "]"
+}
+
+// joinKey appends a map key to path, quoting it if it would not survive splitPath.
+func joinKey(path string, key interface{}) string {
+	s := fmt.Sprint(key)
+	if s == "" || strings.ContainsAny(s, `.[]"`) {
+		return path + "[" + strconv.Quote(s) + "]"
+	}
+	return path + "[" + s + "]"
+}
+
+// settableRoot returns the value a setter should start from; obj must be a
+// non-nil pointer so that updates are visible to the caller.
+func settableRoot(obj interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return reflect.Value{}, fmt.Errorf("cannot set a path on non-pointer %T", obj)
+	}
+	return v.Elem(), nil
+}
+
+// lookupPath resolves segs against v, following pointers and interfaces.
+func lookupPath(v reflect.Value, segs []string, lookup fieldLookup) (reflect.Value, error) {
+	for i, seg := range segs {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return reflect.Value{}, fmt.Errorf("%s: %w", strings.Join(segs[:i+1], "."), ErrPathNotFound)
+			}
+			v = v.Elem()
+		}
+
+		child, err := childValue(v, seg, lookup)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s: %w", strings.Join(segs[:i+1], "."), err)
+		}
+		v = child
+	}
+	return v, nil
+}
+
+// childValue returns the field, element or map entry of v named by seg.
+func childValue(v reflect.Value, seg string, lookup fieldLookup) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.Struct:
+		field, err := structField(v.Type(), seg, lookup)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		child, err := v.FieldByIndexErr(field.Index)
+		if err != nil {
+			return reflect.Value{}, ErrPathNotFound
+		}
+		return child, nil
+	case reflect.Slice, reflect.Array:
+		idx, err := strconv.Atoi(seg)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid index %q", seg)
+		}
+		if idx < 0 || idx >= v.Len() {
+			return reflect.Value{}, ErrPathNotFound
+		}
+		return v.Index(idx), nil
+	case reflect.Map:
+		key, err := mapKey(v.Type().Key(), seg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		elem := v.MapIndex(key)
+		if !elem.IsValid() {
+			return reflect.Value{}, ErrPathNotFound
+		}
+		return elem, nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot index %s with %q", v.Kind(), seg)
+}
+
+// structField looks seg up in t, refusing unexported fields.
+func structField(t reflect.Type, seg string, lookup fieldLookup) (reflect.StructField, error) {
+	field, ok := lookup(t, seg)
+	if !ok {
+		return field, ErrPathNotFound
+	}
+	if field.PkgPath != "" {
+		return field, fmt.Errorf("cannot access non-exported field=%v", seg)
+	}
+	return field, nil
+}
+
+// mapKey converts a path segment into a key of type t.
+func mapKey(t reflect.Type, seg string) (reflect.Value, error) {
+	if t.Kind() == reflect.String {
+		return reflect.ValueOf(seg).Convert(t), nil
+	}
+	return DefaultStringCoercer.Coerce(seg, t)
+}
+
+// updatePath calls fn with a settable value for the element addressed by
+// segs. Nil pointers and maps are allocated and slices grown along the way.
+// Map entries are not addressable, so they are copied, updated and stored back.
+func updatePath(v reflect.Value, segs []string, lookup fieldLookup, fn func(reflect.Value) error) error {
+	if len(segs) == 0 {
+		return fn(v)
+	}
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if !v.CanSet() {
+				return fmt.Errorf("cannot allocate nil %s", v.Type())
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if v.NumMethod() != 0 {
+				return fmt.Errorf("%s: cannot allocate nil %s", segs[0], v.Type())
+			}
+			v.Set(reflect.ValueOf(map[string]interface{}{}))
+		}
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		if err := updatePath(elem, segs, lookup, fn); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	seg, rest := segs[0], segs[1:]
+	switch v.Kind() {
+	case reflect.Struct:
+		field, err := structField(v.Type(), seg, lookup)
+		if err != nil {
+			return fmt.Errorf("%s: %w", seg, err)
+		}
+		return wrapSegment(seg, updatePath(fieldByIndexAlloc(v, field.Index), rest, lookup, fn))
+	case reflect.Slice, reflect.Array:
+		idx, err := strconv.Atoi(seg)
+		if err != nil || idx < 0 {
+			return fmt.Errorf("invalid index %q", seg)
+		}
+		if idx >= v.Len() {
+			if v.Kind() == reflect.Array {
+				return fmt.Errorf("index %d out of range for %s", idx, v.Type())
+			}
+			grow := idx + 1 - v.Len()
+			v.Set(reflect.AppendSlice(v, reflect.MakeSlice(v.Type(), grow, grow)))
+		}
+		return wrapSegment(seg, updatePath(v.Index(idx), rest, lookup, fn))
+	case reflect.Map:
+		key, err := mapKey(v.Type().Key(), seg)
+		if err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if cur := v.MapIndex(key); cur.IsValid() {
+			elem.Set(cur)
+		}
+		if err := updatePath(elem, rest, lookup, fn); err != nil {
+			return wrapSegment(seg, err)
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+	}
+	return fmt.Errorf("cannot index %s with %q", v.Kind(), seg)
+}
+
+// wrapSegment prefixes err with the segment it occurred under.
+func wrapSegment(seg string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s.%w", seg, err)
+}
+
+// fieldByIndexAlloc is reflect.Value.FieldByIndex, allocating nil embedded pointers.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// assignValue stores x into dst, converting between compatible types and
+// parsing strings into the destination type.
+func assignValue(dst reflect.Value, x reflect.Value) error {
+	if !x.IsValid() {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if x.Type().AssignableTo(dst.Type()) {
+		dst.Set(x)
+		return nil
+	}
+	if x.Kind() == reflect.String && dst.Kind() != reflect.String {
+		parsed, err := DefaultStringCoercer.Coerce(x.String(), dst.Type())
+		if err != nil {
+			return err
+		}
+		dst.Set(parsed)
+		return nil
+	}
+	// reflect happily converts ints to strings as runes; that's never wanted here
+	if dst.Kind() == reflect.String && x.Kind() != reflect.String {
+		return fmt.Errorf("cannot assign %s to %s", x.Type(), dst.Type())
+	}
+	if converted, ok := CanConvert(x, dst.Type()); ok {
+		dst.Set(converted)
+		return nil
+	}
+	if dst.Kind() == reflect.Ptr {
+		elem := reflect.New(dst.Type().Elem())
+		if err := assignValue(elem.Elem(), x); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	}
+	return fmt.Errorf("cannot assign %s to %s", x.Type(), dst.Type())
+}
@@ -0,0 +1,151 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type ServerConfig struct {
+	Host    string
+	Port    int
+	Enabled bool
+	Timeout time.Duration
+	Started time.Time
+	Ratio   float64
+	Tags    []string
+	Labels  map[string]string
+	Weights map[string]int
+	IP      net.IP
+	Backup  *ServerConfig
+}
+
+type AppConfig struct {
+	Name    string
+	Server  ServerConfig
+	Servers []ServerConfig
+	ByName  map[string]*ServerConfig
+	Extra   map[string]interface{}
+}
+
+func TestSplitPath(t *testing.T) {
+	Convey("When splitting a path", t, func() {
+		check := func(path string, expected ...string) {
+			segs, err := splitPath(path)
+			So(err, ShouldBeNil)
+			So(segs, ShouldResemble, expected)
+		}
+
+		Convey("Dotted fields should be split like NestedStructIndex", func() {
+			check("A.B.C", "A", "B", "C")
+			check("A.B.", "A", "B")
+		})
+		Convey("Indexes and map keys should become segments", func() {
+			check("Servers[0].Host", "Servers", "0", "Host")
+			check("Labels[env][x]", "Labels", "env", "x")
+		})
+		Convey("Quoted keys may contain dots and brackets", func() {
+			check(`Labels["a.b[c]"].X`, "Labels", "a.b[c]", "X")
+		})
+		Convey("Malformed paths should return an error", func() {
+			for _, path := range []string{"A..B", ".A", "A[0", "A[0]B", `A["x]`} {
+				_, err := splitPath(path)
+				So(err, ShouldNotBeNil)
+			}
+		})
+		Convey("joinKey output should split back to the original key", func() {
+			for _, key := range []string{"plain", "a.b", `q"uote`, ""} {
+				segs, err := splitPath(joinKey("M", key))
+				So(err, ShouldBeNil)
+				So(segs, ShouldResemble, []string{"M", key})
+			}
+		})
+	})
+}
+
+func TestSetPathFromString(t *testing.T) {
+	Convey("When calling SetPathFromString", t, func() {
+		cfg := new(AppConfig)
+
+		Convey("Scalars should be parsed into the field type", func() {
+			So(SetPathFromString(cfg, "Server.Port", "8080"), ShouldBeNil)
+			So(SetPathFromString(cfg, "Server.Enabled", "true"), ShouldBeNil)
+			So(SetPathFromString(cfg, "Server.Ratio", "0.5"), ShouldBeNil)
+			So(SetPathFromString(cfg, "Server.Timeout", "1m30s"), ShouldBeNil)
+			So(cfg.Server.Port, ShouldEqual, 8080)
+			So(cfg.Server.Enabled, ShouldBeTrue)
+			So(cfg.Server.Ratio, ShouldEqual, 0.5)
+			So(cfg.Server.Timeout, ShouldEqual, 90*time.Second)
+		})
+		Convey("Times should be parsed with the configured layouts", func() {
+			So(SetPathFromString(cfg, "Server.Started", "2020-01-02"), ShouldBeNil)
+			So(cfg.Server.Started, ShouldResemble, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC))
+
+			coercer := NewStringCoercer()
+			coercer.TimeLayouts = []string{"02/01/2006"}
+			So(coercer.SetPath(cfg, "Server.Started", "03/04/2021"), ShouldBeNil)
+			So(cfg.Server.Started, ShouldResemble, time.Date(2021, 4, 3, 0, 0, 0, 0, time.UTC))
+		})
+		Convey("Slices and maps should be split on their separators", func() {
+			So(SetPathFromString(cfg, "Server.Tags", "a, b,c"), ShouldBeNil)
+			So(SetPathFromString(cfg, "Server.Weights", "x=1,y=2"), ShouldBeNil)
+			So(cfg.Server.Tags, ShouldResemble, []string{"a", "b", "c"})
+			So(cfg.Server.Weights, ShouldResemble, map[string]int{"x": 1, "y": 2})
+		})
+		Convey("TextUnmarshalers should parse themselves", func() {
+			So(SetPathFromString(cfg, "Server.IP", "10.0.0.1"), ShouldBeNil)
+			So(cfg.Server.IP.String(), ShouldEqual, "10.0.0.1")
+		})
+		Convey("Nil pointers, maps and short slices should be allocated", func() {
+			So(SetPathFromString(cfg, "Server.Backup.Host", "b"), ShouldBeNil)
+			So(SetPathFromString(cfg, "Servers[1].Port", "9"), ShouldBeNil)
+			So(SetPathFromString(cfg, "ByName[web].Labels[env]", "prod"), ShouldBeNil)
+			So(cfg.Server.Backup.Host, ShouldEqual, "b")
+			So(len(cfg.Servers), ShouldEqual, 2)
+			So(cfg.Servers[1].Port, ShouldEqual, 9)
+			So(cfg.ByName["web"].Labels, ShouldResemble, map[string]string{"env": "prod"})
+		})
+		Convey("Generic maps should be created inside interfaces", func() {
+			So(SetPathFromString(cfg, "Extra.a.b", "x"), ShouldBeNil)
+			So(cfg.Extra, ShouldResemble, map[string]interface{}{"a": map[string]interface{}{"b": "x"}})
+		})
+		Convey("Invalid input should return an error", func() {
+			So(SetPathFromString(cfg, "Server.Port", "eighty"), ShouldNotBeNil)
+			So(SetPathFromString(cfg, "Server.Nonexistent", "1"), ShouldNotBeNil)
+			So(SetPathFromString(*cfg, "Name", "x"), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGetAndSetPath(t *testing.T) {
+	Convey("When calling GetPath and SetPath", t, func() {
+		cfg := &AppConfig{Servers: []ServerConfig{{Host: "a"}}}
+
+		Convey("SetPath should convert compatible values", func() {
+			So(SetPath(cfg, "Servers[0].Port", int64(80)), ShouldBeNil)
+			So(SetPath(cfg, "Servers[0].Ratio", "1.5"), ShouldBeNil)
+			So(SetPath(cfg, "Servers[0].Host", 5), ShouldNotBeNil)
+			So(cfg.Servers[0].Port, ShouldEqual, 80)
+			So(cfg.Servers[0].Ratio, ShouldEqual, 1.5)
+		})
+		Convey("GetPath should resolve indexes and report missing values", func() {
+			val, err := GetPath(cfg, "Servers[0].Host")
+			So(err, ShouldBeNil)
+			So(val.Interface(), ShouldEqual, "a")
+
+			_, err = GetPath(cfg, "Servers[3].Host")
+			So(errors.Is(err, ErrPathNotFound), ShouldBeTrue)
+			_, err = GetPath(cfg, "Server.Backup.Host")
+			So(errors.Is(err, ErrPathNotFound), ShouldBeTrue)
+		})
+		Convey("GetPath should work on non-pointer values", func() {
+			val, err := GetPath(*cfg, "Servers")
+			So(err, ShouldBeNil)
+			So(val.Type(), ShouldEqual, reflect.TypeOf([]ServerConfig{}))
+		})
+	})
+}
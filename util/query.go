@@ -0,0 +1,189 @@
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// PathMatch is a value found by QueryPaths along with its concrete path.
+type PathMatch struct {
+	Path  string
+	Value reflect.Value
+}
+
+// QueryPaths returns every value within obj matching pattern. Patterns use the
+// GetPath syntax, where "*" matches any single field, element or key and "**"
+// matches any number of levels (including none), e.g. "Servers[*].Host" or
+// "**.Password". Matches are returned in field, index and sorted key order.
+func QueryPaths(obj interface{}, pattern string) ([]PathMatch, error) {
+	segs, err := splitPath(pattern)
+	if err != nil {
+		return nil, err
+	}
+	q := &pathQuery{guard: newCycleGuard()}
+	q.match(reflect.ValueOf(obj), "", segs)
+	return q.matches, nil
+}
+
+// SetAllPaths sets x at every path within obj matching pattern, converting it
+// the same way SetPath does, and returns the number of values set.
+func SetAllPaths(obj interface{}, pattern string, x interface{}) (int, error) {
+	if _, err := settableRoot(obj); err != nil {
+		return 0, err
+	}
+	matches, err := QueryPaths(obj, pattern)
+	if err != nil {
+		return 0, err
+	}
+	for i, m := range matches {
+		if err := SetPath(obj, m.Path, x); err != nil {
+			return i, fmt.Errorf("%s: %v", m.Path, err)
+		}
+	}
+	return len(matches), nil
+}
+
+// ptrKey identifies a pointer target for cycle detection.
+type ptrKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+type pathQuery struct {
+	matches []PathMatch
+	// tracks pointers on the current descent, so "**" terminates on cyclic data
+	guard cycleGuard
+}
+
+func (q *pathQuery) match(v reflect.Value, path string, segs []string) {
+	if len(segs) == 0 {
+		q.matches = append(q.matches, PathMatch{path, v})
+		return
+	}
+
+	v, leave, ok := q.guard.enter(v)
+	if !ok {
+		return
+	}
+	defer leave()
+
+	switch seg := segs[0]; seg {
+	case "**":
+		q.match(v, path, segs[1:])
+		eachChild(v, path, func(childPath string, _ reflect.StructField, child reflect.Value) {
+			q.match(child, childPath, segs)
+		})
+	case "*":
+		eachChild(v, path, func(childPath string, _ reflect.StructField, child reflect.Value) {
+			q.match(child, childPath, segs[1:])
+		})
+	default:
+		child, err := childValue(v, seg, fieldByGoName)
+		if err != nil {
+			return
+		}
+		q.match(child, childPathOf(v, path, seg), segs[1:])
+	}
+}
+
+// cycleGuard records the pointers on the current descent through a value.
+type cycleGuard map[ptrKey]bool
+
+func newCycleGuard() cycleGuard {
+	return cycleGuard{}
+}
+
+// enter follows pointers and interfaces from v, returning false for nil
+// values and for pointers already on the current descent. Callers must call
+// leave once they are done with the returned element.
+func (g cycleGuard) enter(v reflect.Value) (elem reflect.Value, leave func(), ok bool) {
+	var entered []ptrKey
+	leave = func() {
+		for _, key := range entered {
+			delete(g, key)
+		}
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			leave()
+			return v, nil, false
+		}
+		if v.Kind() == reflect.Ptr {
+			key := ptrKey{v.Pointer(), v.Type()}
+			if g[key] {
+				leave()
+				return v, nil, false
+			}
+			g[key] = true
+			entered = append(entered, key)
+		}
+		v = v.Elem()
+	}
+	return v, leave, true
+}
+
+// childPathOf joins seg onto path in the canonical form for v's kind.
+func childPathOf(v reflect.Value, path, seg string) string {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return joinKey(path, seg)
+	}
+	return joinField(path, seg)
+}
+
+// eachChild calls fn for each exported field, element or map entry of v.
+// For elements and entries, field is the zero StructField.
+func eachChild(v reflect.Value, path string, fn func(childPath string, field reflect.StructField, child reflect.Value)) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if field := t.Field(i); field.PkgPath == "" {
+				fn(joinField(path, field.Name), field, v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fn(joinIndex(path, i), reflect.StructField{}, v.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range sortedMapKeys(v) {
+			fn(joinKey(path, key.Interface()), reflect.StructField{}, v.MapIndex(key))
+		}
+	}
+}
+
+// sortedMapKeys returns the keys of map v ordered by their printed form.
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sortValues(keys)
+	return keys
+}
+
+// sortValues orders values by their printed form.
+func sortValues(values []reflect.Value) {
+	sort.Slice(values, func(i, j int) bool {
+		return fmt.Sprint(values[i].Interface()) < fmt.Sprint(values[j].Interface())
+	})
+}
+
+// pathMatches reports whether the segments of a concrete path match those
+// of a QueryPaths pattern.
+func pathMatches(pattern, segs []string) bool {
+	if len(pattern) == 0 {
+		return len(segs) == 0
+	}
+	switch pattern[0] {
+	case "**":
+		for i := 0; i <= len(segs); i++ {
+			if pathMatches(pattern[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(segs) > 0 && pathMatches(pattern[1:], segs[1:])
+	}
+	return len(segs) > 0 && pattern[0] == segs[0] && pathMatches(pattern[1:], segs[1:])
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"testing"
+)
+
+type credentials struct {
+	User     string
+	Password string
+}
+
+type queryConfig struct {
+	Admin    credentials
+	Password string
+	Servers  []*ServerConfig
+	DBs      map[string]credentials
+	Next     *queryConfig
+}
+
+func getQueryConfig() *queryConfig {
+	return &queryConfig{
+		Admin:    credentials{"root", "pw1"},
+		Password: "pw0",
+		Servers:  []*ServerConfig{{Host: "a"}, nil, {Host: "c"}},
+		DBs:      map[string]credentials{"main": {"u", "pw3"}, "logs.archive": {"v", "pw2"}},
+	}
+}
+
+func TestQueryPaths(t *testing.T) {
+	Convey("When calling QueryPaths", t, func() {
+		cfg := getQueryConfig()
+
+		paths := func(pattern string) []string {
+			matches, err := QueryPaths(cfg, pattern)
+			So(err, ShouldBeNil)
+			res := []string{}
+			for _, m := range matches {
+				res = append(res, m.Path)
+			}
+			return res
+		}
+
+		Convey("A pattern without wildcards should resolve a single path", func() {
+			So(paths("Admin.User"), ShouldResemble, []string{"Admin.User"})
+			So(paths("Admin.Missing"), ShouldResemble, []string{})
+		})
+		Convey("* should match every element of a slice, skipping nils", func() {
+			matches, err := QueryPaths(cfg, "Servers[*].Host")
+			So(err, ShouldBeNil)
+			So(len(matches), ShouldEqual, 2)
+			So(matches[0].Path, ShouldEqual, "Servers[0].Host")
+			So(matches[1].Path, ShouldEqual, "Servers[2].Host")
+			So(matches[1].Value.Interface(), ShouldEqual, "c")
+		})
+		Convey("* should match map keys and struct fields", func() {
+			So(paths("DBs.*.User"), ShouldResemble, []string{`DBs["logs.archive"].User`, "DBs[main].User"})
+			So(paths("Admin.*"), ShouldResemble, []string{"Admin.User", "Admin.Password"})
+		})
+		Convey("** should descend recursively", func() {
+			So(paths("**.Password"), ShouldResemble, []string{
+				"Password", "Admin.Password", `DBs["logs.archive"].Password`, "DBs[main].Password",
+			})
+		})
+		Convey("** should terminate on pointer cycles", func() {
+			cfg.Next = cfg
+			So(paths("**.User"), ShouldResemble, []string{
+				"Admin.User", `DBs["logs.archive"].User`, "DBs[main].User",
+			})
+		})
+	})
+}
+
+func TestSetAllPaths(t *testing.T) {
+	Convey("When calling SetAllPaths", t, func() {
+		cfg := getQueryConfig()
+
+		Convey("Every match should be set, including map entries", func() {
+			n, err := SetAllPaths(cfg, "**.Password", "***")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 4)
+			So(cfg.Password, ShouldEqual, "***")
+			So(cfg.Admin.Password, ShouldEqual, "***")
+			So(cfg.DBs["main"].Password, ShouldEqual, "***")
+			So(cfg.DBs["logs.archive"].Password, ShouldEqual, "***")
+		})
+		Convey("Values should be converted to the target type", func() {
+			n, err := SetAllPaths(cfg, "Servers[*].Port", "8080")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			So(cfg.Servers[2].Port, ShouldEqual, 8080)
+			So(cfg.Servers[1], ShouldBeNil)
+		})
+		Convey("A non-pointer object should be rejected", func() {
+			_, err := SetAllPaths(*cfg, "Password", "x")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
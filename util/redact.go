@@ -0,0 +1,213 @@
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+type RedactOptions struct {
+	// Mask replaces redacted strings. Other redacted values are zeroed, so a
+	// redacted number prints as 0, just like a real zero.
+	Mask string
+	// Paths selects values to redact by QueryPaths pattern, e.g. "DB.Password"
+	// or "**.Token".
+	Paths []string
+	// Names redacts struct fields and string map keys whose name matches.
+	Names *regexp.Regexp
+}
+
+// DefaultRedactOptions are used by Redact and Redacted.
+var DefaultRedactOptions = RedactOptions{
+	Mask:  "******",
+	Names: regexp.MustCompile(`(?i)passw(or)?d|secret|token|api_?key|credential`),
+}
+
+// Redact returns a deep copy of obj with its sensitive values masked, as
+// selected by DefaultRedactOptions and by `secret:"true"` struct tags.
+func Redact[T any](obj T) T {
+	out, err := RedactWithOptions(obj, DefaultRedactOptions)
+	if err != nil {
+		// the default options have no paths that could be invalid
+		panic(err)
+	}
+	return out
+}
+
+// RedactWithOptions returns a deep copy of obj in which every value selected
+// by opts, or held by a field tagged `secret:"true"`, is redacted: strings,
+// including those within slices, maps and pointers, are replaced by
+// opts.Mask, other values are zeroed; a secret int field becomes 0, which
+// cannot be told apart from a real 0. Unexported fields are redacted too.
+func RedactWithOptions[T any](obj T, opts RedactOptions) (T, error) {
+	var out T
+	redacted, err := redactValue(reflect.ValueOf(&obj).Elem(), opts)
+	if err != nil {
+		return out, err
+	}
+	reflect.ValueOf(&out).Elem().Set(redacted)
+	return out, nil
+}
+
+// redactValue returns a redacted, addressable deep copy of v.
+func redactValue(v reflect.Value, opts RedactOptions) (reflect.Value, error) {
+	cp := deepCopyValue(v)
+	r := &redactor{opts: opts, guard: newCycleGuard()}
+	r.redact(cp)
+
+	for _, pattern := range opts.Paths {
+		matches, err := QueryPaths(cp.Addr().Interface(), pattern)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		for _, match := range matches {
+			segs, err := splitPath(match.Path)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if err := updatePath(cp, segs, fieldByGoName, func(dst reflect.Value) error {
+				r.mask(dst)
+				return nil
+			}); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+	}
+	return cp, nil
+}
+
+type redactor struct {
+	opts  RedactOptions
+	guard cycleGuard
+}
+
+func (r *redactor) matchesName(name string) bool {
+	return r.opts.Names != nil && r.opts.Names.MatchString(name)
+}
+
+// redact masks the sensitive values within the settable v.
+func (r *redactor) redact(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem, leave, ok := r.guard.enter(v)
+		if !ok {
+			return
+		}
+		defer leave()
+		r.redact(elem)
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		r.redact(elem)
+		v.Set(elem)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			fv := settableField(v, i)
+			if secret, _ := strconv.ParseBool(field.Tag.Get("secret")); secret || r.matchesName(field.Name) {
+				r.mask(fv)
+				continue
+			}
+			r.redact(fv)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			r.redact(v.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range sortedMapKeys(v) {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			if key.Kind() == reflect.String && r.matchesName(key.String()) {
+				r.mask(elem)
+			} else {
+				r.redact(elem)
+			}
+			v.SetMapIndex(key, elem)
+		}
+	}
+}
+
+// mask replaces the strings within the settable v with the mask and zeroes
+// everything else.
+func (r *redactor) mask(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() > 0 {
+			v.SetString(r.opts.Mask)
+		}
+	case reflect.Ptr:
+		if !v.IsNil() {
+			r.mask(v.Elem())
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		r.mask(elem)
+		v.Set(elem)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			r.mask(v.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range sortedMapKeys(v) {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			r.mask(elem)
+			v.SetMapIndex(key, elem)
+		}
+	default:
+		v.Set(reflect.Zero(v.Type()))
+	}
+}
+
+// Redacted wraps a value so that it is always formatted redacted, with
+// DefaultRedactOptions, whatever the verb:
+//
+//	log.Printf("loaded config %+v", util.Redacted{cfg})
+type Redacted struct {
+	Value interface{}
+}
+
+func (r Redacted) Format(f fmt.State, verb rune) {
+	var value interface{}
+	if r.Value != nil {
+		redacted, err := redactValue(reflect.ValueOf(r.Value), DefaultRedactOptions)
+		if err != nil {
+			fmt.Fprintf(f, "%%!%c(redact error: %v)", verb, err)
+			return
+		}
+		value = redacted.Interface()
+	}
+	fmt.Fprintf(f, formatDirective(f, verb), value)
+}
+
+// formatDirective rebuilds the directive, such as "%+8v", that f was
+// formatted with.
+func formatDirective(f fmt.State, verb rune) string {
+	directive := "%"
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			directive += string(flag)
+		}
+	}
+	if width, ok := f.Width(); ok {
+		directive += strconv.Itoa(width)
+	}
+	if prec, ok := f.Precision(); ok {
+		directive += "." + strconv.Itoa(prec)
+	}
+	return directive + string(verb)
+}
@@ -0,0 +1,94 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+type redactDB struct {
+	User     string
+	Password string
+	Key      []byte `secret:"true"`
+	Port     int    `secret:"true"`
+}
+
+type redactConfig struct {
+	Name   string
+	DB     *redactDB
+	Backup **redactDB
+	Extra  map[string]interface{}
+	Tokens []string
+	Note   string
+	apiKey string
+}
+
+func TestRedact(t *testing.T) {
+	Convey("When redacting a config", t, func() {
+		backup := &redactDB{User: "b", Password: "bp"}
+		cfg := &redactConfig{
+			Name:   "app",
+			DB:     &redactDB{"u", "p", []byte("k"), 5432},
+			Backup: &backup,
+			Extra: map[string]interface{}{
+				"auth_token": "t",
+				"region":     "eu",
+				"nested":     map[string]interface{}{"password": "q"},
+			},
+			Tokens: []string{"a", ""},
+			Note:   "hi",
+			apiKey: "k",
+		}
+		r := Redact(cfg)
+
+		Convey("Fields and map keys should be redacted by name", func() {
+			So(r.DB.Password, ShouldEqual, "******")
+			So(r.DB.User, ShouldEqual, "u")
+			So(r.Extra["auth_token"], ShouldEqual, "******")
+			So(r.Extra["region"], ShouldEqual, "eu")
+			So(r.Extra["nested"], ShouldResemble, map[string]interface{}{"password": "******"})
+			So(r.Tokens, ShouldResemble, []string{"******", ""})
+			So(r.apiKey, ShouldEqual, "******")
+		})
+		Convey("Fields tagged secret should be redacted, non-strings zeroed", func() {
+			So(r.DB.Key, ShouldBeNil)
+			// indistinguishable from a port that really is 0
+			So(r.DB.Port, ShouldEqual, 0)
+		})
+		Convey("Nested pointers should be followed", func() {
+			So((*r.Backup).User, ShouldEqual, "b")
+			So((*r.Backup).Password, ShouldEqual, "******")
+		})
+		Convey("The original should be left untouched", func() {
+			So(cfg.DB.Password, ShouldEqual, "p")
+			So(cfg.DB.Port, ShouldEqual, 5432)
+			So(backup.Password, ShouldEqual, "bp")
+			So(cfg.Extra["auth_token"], ShouldEqual, "t")
+		})
+	})
+
+	Convey("Options should select values by path and name", t, func() {
+		cfg := redactConfig{Name: "app", DB: &redactDB{User: "u", Password: "p"}, Note: "hi"}
+		r, err := RedactWithOptions(cfg, RedactOptions{
+			Mask:  "x",
+			Paths: []string{"Note", "DB.User"},
+			Names: regexp.MustCompile("^Name$"),
+		})
+		So(err, ShouldBeNil)
+		So(r.Name, ShouldEqual, "x")
+		So(r.Note, ShouldEqual, "x")
+		So(r.DB.User, ShouldEqual, "x")
+		So(r.DB.Password, ShouldEqual, "p")
+	})
+
+	Convey("Redacted should format the redacted value", t, func() {
+		db := &redactDB{User: "u", Password: "p", Port: 5432}
+		So(fmt.Sprintf("%v", Redacted{db}), ShouldEqual, "&{u ****** [] 0}")
+		So(fmt.Sprintf("%+v", Redacted{*db}), ShouldEqual, "{User:u Password:****** Key:[] Port:0}")
+		So(fmt.Sprintf("[%4v]", Redacted{3}), ShouldEqual, "[   3]")
+		So(fmt.Sprintf("%v", Redacted{nil}), ShouldEqual, "<nil>")
+		So(db.Password, ShouldEqual, "p")
+	})
+}
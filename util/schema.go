@@ -0,0 +1,406 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSONSchemaDraft is the $schema of generated schemas.
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is a JSON Schema (draft 2020-12) document or subschema.
+type JSONSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	ID          string                 `json:"$id,omitempty"`
+	Ref         string                 `json:"$ref,omitempty"`
+	Defs        map[string]*JSONSchema `json:"$defs,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Default     interface{}            `json:"default,omitempty"`
+
+	Type   SchemaTypes     `json:"type,omitempty"`
+	Enum   []interface{}   `json:"enum,omitempty"`
+	Const  json.RawMessage `json:"const,omitempty"`
+	Format string          `json:"format,omitempty"`
+
+	Minimum          *float64 `json:"minimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty"`
+	MultipleOf       *float64 `json:"multipleOf,omitempty"`
+
+	MinLength *int   `json:"minLength,omitempty"`
+	MaxLength *int   `json:"maxLength,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+
+	Items       *JSONSchema `json:"items,omitempty"`
+	MinItems    *int        `json:"minItems,omitempty"`
+	MaxItems    *int        `json:"maxItems,omitempty"`
+	UniqueItems bool        `json:"uniqueItems,omitempty"`
+
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	MinProperties        *int                   `json:"minProperties,omitempty"`
+	MaxProperties        *int                   `json:"maxProperties,omitempty"`
+
+	AllOf []*JSONSchema `json:"allOf,omitempty"`
+	AnyOf []*JSONSchema `json:"anyOf,omitempty"`
+	OneOf []*JSONSchema `json:"oneOf,omitempty"`
+	Not   *JSONSchema   `json:"not,omitempty"`
+
+	// boolean is set for the schemas true, which accepts everything, and
+	// false, which accepts nothing.
+	boolean *bool
+}
+
+// BoolSchema returns the schema true or false.
+func BoolSchema(b bool) *JSONSchema {
+	return &JSONSchema{boolean: &b}
+}
+
+// jsonSchemaFields has the fields of JSONSchema without its methods.
+type jsonSchemaFields JSONSchema
+
+func (s *JSONSchema) MarshalJSON() ([]byte, error) {
+	if s.boolean != nil {
+		return json.Marshal(*s.boolean)
+	}
+	return json.Marshal((*jsonSchemaFields)(s))
+}
+
+func (s *JSONSchema) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*s = JSONSchema{boolean: &b}
+		return nil
+	}
+	*s = JSONSchema{}
+	return json.Unmarshal(data, (*jsonSchemaFields)(s))
+}
+
+// SchemaTypes is the "type" keyword, which is written as a plain string
+// when it holds a single type.
+type SchemaTypes []string
+
+func (t SchemaTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *SchemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = SchemaTypes{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// GenerateJSONSchema returns the schema of the JSON encoding of t, as
+// written by WriteJsonToFile. Properties are named by `json` tags. Each
+// property's `description` tag becomes its description and its `default` tag
+// its default, and the required, min, max, len, oneof and regex rules of its
+// `validate` tag become the equivalent keywords. Named struct types are
+// defined once in $defs and referenced from every use. Structs reject
+// unknown properties. Pointers, slices and maps also accept null, as they
+// are written when nil, unless their field is required.
+func GenerateJSONSchema(t reflect.Type) (*JSONSchema, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	g := &schemaGenerator{root: t, names: map[reflect.Type]string{}, defs: map[string]*JSONSchema{}}
+
+	var schema *JSONSchema
+	var err error
+	if t.Kind() == reflect.Struct {
+		schema, err = g.structSchema(t)
+	} else {
+		schema, err = g.schema(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = JSONSchemaDraft
+	if t.Name() != "" {
+		schema.Title = t.Name()
+	}
+	if len(g.defs) > 0 {
+		schema.Defs = g.defs
+	}
+	return schema, nil
+}
+
+type schemaGenerator struct {
+	root  reflect.Type
+	names map[reflect.Type]string
+	defs  map[string]*JSONSchema
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) (*JSONSchema, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &JSONSchema{Type: SchemaTypes{"string"}, Format: "date-time"}, nil
+	case t == durationType:
+		// nanoseconds in JSON, but YAML also accepts strings such as "1m30s"
+		return &JSONSchema{Type: SchemaTypes{"integer", "string"}}, nil
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return &JSONSchema{Type: SchemaTypes{"string"}}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: SchemaTypes{"boolean"}}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &JSONSchema{Type: SchemaTypes{"integer"}}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		zero := 0.0
+		return &JSONSchema{Type: SchemaTypes{"integer"}, Minimum: &zero}, nil
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: SchemaTypes{"number"}}, nil
+	case reflect.String:
+		return &JSONSchema{Type: SchemaTypes{"string"}}, nil
+	case reflect.Interface:
+		return &JSONSchema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes byte slices base64 encoded
+			return &JSONSchema{Type: SchemaTypes{"string"}, Format: "byte"}, nil
+		}
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if isNilableKind(t.Elem().Kind()) {
+			items = nullableSchema(items)
+		}
+		s := &JSONSchema{Type: SchemaTypes{"array"}, Items: items}
+		if t.Kind() == reflect.Array {
+			n := t.Len()
+			s.MinItems, s.MaxItems = &n, &n
+		}
+		return s, nil
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !reflect.PtrTo(t.Key()).Implements(textUnmarshalerType) {
+				return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+			}
+		}
+		elem, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if isNilableKind(t.Elem().Kind()) {
+			elem = nullableSchema(elem)
+		}
+		return &JSONSchema{Type: SchemaTypes{"object"}, AdditionalProperties: elem}, nil
+	case reflect.Struct:
+		if t == g.root {
+			return &JSONSchema{Ref: "#"}, nil
+		}
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.defName(t)
+			g.names[t] = name
+			def, err := g.structSchema(t)
+			if err != nil {
+				return nil, err
+			}
+			g.defs[name] = def
+		}
+		return &JSONSchema{Ref: "#/$defs/" + jsonPointerEscaper.Replace(name)}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// defName names the definition of t, qualifying it with its package if
+// another type already took its plain name.
+func (g *schemaGenerator) defName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := g.defs[name]; taken {
+		name = t.String()
+	}
+	for i := 2; ; i++ {
+		if _, taken := g.defs[name]; !taken {
+			break
+		}
+		name = t.String() + strconv.Itoa(i)
+	}
+	// reserve the name while the definition is generated
+	g.defs[name] = nil
+	return name
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) (*JSONSchema, error) {
+	s := &JSONSchema{
+		Type:                 SchemaTypes{"object"},
+		Properties:           map[string]*JSONSchema{},
+		AdditionalProperties: BoolSchema(false),
+	}
+	for _, field := range taggedFields(t, "json") {
+		if field.hasOption("string") {
+			// ",string" fields are quoted, whatever their type
+			s.Properties[field.Name] = &JSONSchema{Type: SchemaTypes{"string"}}
+			continue
+		}
+		prop, err := g.schema(field.Field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", t, field.Field.Name, err)
+		}
+		required, err := applySchemaTags(prop, field.Field)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", t, field.Field.Name, err)
+		}
+		if required {
+			s.Required = append(s.Required, field.Name)
+		} else if isNilableKind(field.Field.Type.Kind()) {
+			prop = nullableSchema(prop)
+		}
+		s.Properties[field.Name] = prop
+	}
+	return s, nil
+}
+
+// isNilableKind reports whether values of kind k are written as null by
+// encoding/json when they are nil.
+func isNilableKind(k reflect.Kind) bool {
+	return k == reflect.Ptr || k == reflect.Slice || k == reflect.Map
+}
+
+// nullableSchema returns s extended to accept null.
+func nullableSchema(s *JSONSchema) *JSONSchema {
+	switch {
+	case len(s.Type) > 0:
+		s.Type = append(s.Type, "null")
+		if len(s.Enum) > 0 {
+			s.Enum = append(s.Enum, nil)
+		}
+	case s.Ref != "":
+		wrapped := &JSONSchema{Description: s.Description, Default: s.Default}
+		s.Description, s.Default = "", nil
+		wrapped.AnyOf = []*JSONSchema{s, {Type: SchemaTypes{"null"}}}
+		return wrapped
+	}
+	// anything else, such as the schema of an interface, accepts null already
+	return s
+}
+
+// applySchemaTags adds the `description`, `default` and `validate` tags of
+// field to s, reporting whether the field is required.
+func applySchemaTags(s *JSONSchema, field reflect.StructField) (bool, error) {
+	s.Description = field.Tag.Get("description")
+	t := field.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if tag, ok := field.Tag.Lookup("default"); ok {
+		def, err := schemaValue(tag, t)
+		if err != nil {
+			return false, fmt.Errorf("invalid default: %v", err)
+		}
+		s.Default = def
+	}
+
+	required := false
+	for _, rule := range parseValidateTag(field.Tag.Get("validate")) {
+		switch rule.name {
+		case "required":
+			required = true
+		case "min", "max", "len":
+			if err := applySchemaBound(s, t, rule); err != nil {
+				return false, err
+			}
+		case "oneof":
+			for _, option := range strings.Split(rule.param, "|") {
+				v, err := schemaValue(option, t)
+				if err != nil {
+					return false, fmt.Errorf("invalid oneof option: %v", err)
+				}
+				s.Enum = append(s.Enum, v)
+			}
+		case "regex":
+			s.Pattern = rule.param
+		}
+	}
+	return required, nil
+}
+
+func applySchemaBound(s *JSONSchema, t reflect.Type, rule validateRule) error {
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// the length of the base64 text is not the length of the slice
+			return nil
+		}
+		n, err := strconv.Atoi(rule.param)
+		if err != nil {
+			return fmt.Errorf("invalid %s parameter %q", rule.name, rule.param)
+		}
+		var min, max **int
+		switch t.Kind() {
+		case reflect.String:
+			min, max = &s.MinLength, &s.MaxLength
+		case reflect.Map:
+			min, max = &s.MinProperties, &s.MaxProperties
+		default:
+			min, max = &s.MinItems, &s.MaxItems
+		}
+		if rule.name != "max" {
+			*min = &n
+		}
+		if rule.name != "min" {
+			*max = &n
+		}
+		return nil
+	}
+
+	if rule.name == "len" {
+		return nil
+	}
+	v, err := schemaValue(rule.param, t)
+	if err != nil {
+		return fmt.Errorf("invalid %s parameter: %v", rule.name, err)
+	}
+	f, ok := v.(float64)
+	if !ok {
+		// bounds on times and the like have no JSON Schema equivalent
+		return nil
+	}
+	if rule.name == "min" {
+		s.Minimum = &f
+	} else {
+		s.Maximum = &f
+	}
+	return nil
+}
+
+// schemaValue parses s into a value of type t and returns its generic JSON
+// encoding.
+func schemaValue(s string, t reflect.Type) (interface{}, error) {
+	v, err := DefaultStringCoercer.Coerce(s, t)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	err = json.Unmarshal(data, &generic)
+	return generic, err
+}
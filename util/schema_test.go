@@ -0,0 +1,238 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type schemaEndpoint struct {
+	URL     string        `json:"url" validate:"required,regex=^https?://" description:"Where to send requests"`
+	Timeout time.Duration `json:"timeout,omitempty" default:"30s"`
+}
+
+type schemaConfig struct {
+	Name      string            `json:"name" validate:"required,max=20"`
+	Port      int               `json:"port" default:"8080" validate:"min=1,max=65535"`
+	Level     string            `json:"level" validate:"oneof=debug|info"`
+	Primary   schemaEndpoint    `json:"primary"`
+	Fallbacks []*schemaEndpoint `json:"fallbacks,omitempty" validate:"max=3"`
+	Labels    map[string]string `json:"labels"`
+	Started   time.Time         `json:"started"`
+	Next      *schemaConfig     `json:"next"`
+	Ratio     float32
+	Internal  int `json:"-"`
+}
+
+func TestGenerateJSONSchema(t *testing.T) {
+	Convey("When generating the schema of a struct", t, func() {
+		s, err := GenerateJSONSchema(reflect.TypeOf(&schemaConfig{}))
+		So(err, ShouldBeNil)
+
+		Convey("Properties should be named by json tags", func() {
+			So(s.Schema, ShouldEqual, JSONSchemaDraft)
+			So(s.Type, ShouldResemble, SchemaTypes{"object"})
+			So(len(s.Properties), ShouldEqual, 9)
+			So(s.Properties["Ratio"].Type, ShouldResemble, SchemaTypes{"number"})
+			So(s.Properties["Internal"], ShouldBeNil)
+			So(s.Properties["labels"].AdditionalProperties.Type, ShouldResemble, SchemaTypes{"string"})
+			So(s.Properties["started"].Format, ShouldEqual, "date-time")
+		})
+
+		Convey("Tags should become keywords", func() {
+			So(s.Required, ShouldResemble, []string{"name"})
+			So(*s.Properties["name"].MaxLength, ShouldEqual, 20)
+			So(*s.Properties["port"].Minimum, ShouldEqual, 1)
+			So(*s.Properties["port"].Maximum, ShouldEqual, 65535)
+			So(s.Properties["port"].Default, ShouldEqual, 8080)
+			So(s.Properties["level"].Enum, ShouldResemble, []interface{}{"debug", "info"})
+			So(*s.Properties["fallbacks"].MaxItems, ShouldEqual, 3)
+		})
+
+		Convey("Named structs should be defined once and referenced", func() {
+			So(s.Properties["primary"].Ref, ShouldEqual, "#/$defs/schemaEndpoint")
+			So(s.Properties["fallbacks"].Items.AnyOf[0].Ref, ShouldEqual, "#/$defs/schemaEndpoint")
+			So(s.Properties["next"].AnyOf[0].Ref, ShouldEqual, "#")
+			def := s.Defs["schemaEndpoint"]
+			So(def.Required, ShouldResemble, []string{"url"})
+			So(def.Properties["url"].Pattern, ShouldEqual, "^https?://")
+			So(def.Properties["url"].Description, ShouldEqual, "Where to send requests")
+		})
+
+		Convey("Nil pointers, slices and maps should validate as null", func() {
+			So(s.Properties["labels"].Type, ShouldResemble, SchemaTypes{"object", "null"})
+			So(s.Properties["next"].AnyOf[1].Type, ShouldResemble, SchemaTypes{"null"})
+			So(s.ValidateJSON([]byte(`{"name": "x", "primary": {"url": "http://a"}, "fallbacks": null, "labels": null, "next": null}`)), ShouldBeNil)
+			So(s.ValidateJSON([]byte(`{"name": null}`)), ShouldNotBeNil)
+
+			type required struct {
+				Inner *schemaEndpoint `json:"inner" validate:"required"`
+				Tags  []string        `json:"tags" validate:"required"`
+			}
+			rs, err := GenerateJSONSchema(reflect.TypeOf(required{}))
+			So(err, ShouldBeNil)
+			So(rs.Properties["tags"].Type, ShouldResemble, SchemaTypes{"array"})
+			So(rs.ValidateJSON([]byte(`{"inner": null, "tags": null}`)), ShouldNotBeNil)
+		})
+
+		Convey("It should survive a JSON round trip", func() {
+			data, err := json.Marshal(s)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldContainSubstring, `"additionalProperties":false`)
+			So(string(data), ShouldContainSubstring, `"type":"object"`)
+
+			var back JSONSchema
+			So(json.Unmarshal(data, &back), ShouldBeNil)
+			again, err := json.Marshal(&back)
+			So(err, ShouldBeNil)
+			So(string(again), ShouldEqual, string(data))
+		})
+
+		Convey("Unsupported types should fail", func() {
+			_, err := GenerateJSONSchema(reflect.TypeOf(struct{ C chan int }{}))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+const validateTestSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["name", "server"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 2, "pattern": "^[a-z]+$"},
+		"mode": {"enum": ["dev", "prod"]},
+		"version": {"const": 2},
+		"server": {"$ref": "#/$defs/server"},
+		"backups": {"type": "array", "items": {"$ref": "#/$defs/server"}, "maxItems": 2},
+		"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+		"port": {"oneOf": [{"type": "integer", "minimum": 1, "maximum": 65535}, {"type": "string", "pattern": "^\\$"}]},
+		"ratio": {"anyOf": [{"type": "number", "exclusiveMaximum": 1}, {"type": "null"}]},
+		"debug": {"allOf": [{"type": "boolean"}, {"not": {"const": true}}]}
+	},
+	"$defs": {
+		"server": {
+			"type": "object",
+			"required": ["host"],
+			"properties": {
+				"host": {"type": "string"},
+				"port": {"type": "integer", "minimum": 1}
+			}
+		}
+	}
+}`
+
+func TestValidateJSONSchema(t *testing.T) {
+	Convey("When validating documents against a schema", t, func() {
+		var schema JSONSchema
+		So(json.Unmarshal([]byte(validateTestSchema), &schema), ShouldBeNil)
+
+		Convey("Valid documents should pass", func() {
+			So(schema.ValidateJSON([]byte(`{"name": "app", "server": {"host": "a"}, "port": 80, "ratio": null, "debug": false}`)), ShouldBeNil)
+			So(schema.ValidateYAML([]byte("name: app\nserver:\n  host: a\nport: $PORT\nmode: prod\nversion: 2\n")), ShouldBeNil)
+			So(schema.ValidateValue(map[string]interface{}{"name": "app", "server": map[string]interface{}{"host": "a", "port": 1}}), ShouldBeNil)
+		})
+
+		Convey("JSON violations should be located by pointer, line and column", func() {
+			doc := `{
+  "name": "App",
+  "server": {"port": 0},
+  "backups": [
+    {"host": "b1"},
+    {"host": 7}
+  ],
+  "extra": true
+}`
+			err := schema.ValidateJSON([]byte(doc))
+			So(err, ShouldNotBeNil)
+			errs, ok := err.(SchemaErrors)
+			So(ok, ShouldBeTrue)
+
+			found := map[string]*SchemaError{}
+			for _, e := range errs {
+				found[e.Pointer+" "+e.Keyword] = e
+			}
+			So(len(errs), ShouldEqual, 5)
+			So(found["/name pattern"].Line, ShouldEqual, 2)
+			So(found["/name pattern"].Column, ShouldEqual, 11)
+			So(found["/server/host required"].Line, ShouldEqual, 3)
+			So(found["/server/port minimum"].Column, ShouldEqual, 22)
+			So(found["/backups/1/host type"].Line, ShouldEqual, 6)
+			So(found["/backups/1/host type"].Column, ShouldEqual, 14)
+			So(found["/extra additionalProperties"].Line, ShouldEqual, 8)
+			So(errs[0].Error(), ShouldEqual, "line 2, column 11: /name: must match ^[a-z]+$")
+		})
+
+		Convey("YAML violations should be located by pointer, line and column", func() {
+			doc := `# service
+name: app
+server:
+  host: a
+  port: -1
+backups:
+- host: b1
+- port: 2
+labels: {team: 3}
+mode: test
+`
+			err := schema.ValidateYAML([]byte(doc))
+			So(err, ShouldNotBeNil)
+			errs := err.(SchemaErrors)
+			So(len(errs), ShouldEqual, 4)
+			So(errs[0].Pointer, ShouldEqual, "/server/port")
+			So(errs[0].Line, ShouldEqual, 5)
+			So(errs[0].Column, ShouldEqual, 9)
+			So(errs[1].Pointer, ShouldEqual, "/backups/1/host")
+			So(errs[1].Line, ShouldEqual, 8)
+			So(errs[1].Column, ShouldEqual, 3)
+			So(errs[2].Pointer, ShouldEqual, "/labels/team")
+			So(errs[2].Line, ShouldEqual, 9)
+			So(errs[2].Column, ShouldEqual, 9)
+			So(errs[3].Pointer, ShouldEqual, "/mode")
+			So(errs[3].Message, ShouldEqual, `must be one of "dev", "prod"`)
+		})
+
+		Convey("Combinators should be checked", func() {
+			err := schema.ValidateValue(map[string]interface{}{
+				"name": "app", "server": map[string]interface{}{"host": "a"},
+				"port": "80", "ratio": 1, "debug": true, "version": 3,
+			})
+			So(err, ShouldNotBeNil)
+			keywords := []string{}
+			for _, e := range err.(SchemaErrors) {
+				keywords = append(keywords, e.Pointer+" "+e.Keyword)
+			}
+			So(keywords, ShouldResemble, []string{"/debug not", "/port oneOf", "/ratio anyOf", "/version const"})
+		})
+
+		Convey("Generated schemas should validate documents", func() {
+			generated, err := GenerateJSONSchema(reflect.TypeOf(schemaConfig{}))
+			So(err, ShouldBeNil)
+			So(generated.ValidateJSON([]byte(`{"name": "a", "primary": {"url": "https://x"}, "next": {"name": "b"}}`)), ShouldBeNil)
+			err = generated.ValidateJSON([]byte(`{"primary": {"url": "ftp://x"}, "next": {"port": 0}}`))
+			So(err, ShouldNotBeNil)
+			keywords := []string{}
+			for _, e := range err.(SchemaErrors) {
+				keywords = append(keywords, e.Pointer+" "+e.Keyword)
+			}
+			// next may also be null, so its own errors are summed up by anyOf
+			So(keywords, ShouldResemble, []string{"/name required", "/primary/url pattern", "/next anyOf"})
+		})
+
+		Convey("Syntax errors and bad references should be plain errors", func() {
+			err := schema.ValidateJSON([]byte("{\n  \"name\": }"))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "line 2, column 11:")
+			_, ok := err.(SchemaErrors)
+			So(ok, ShouldBeFalse)
+
+			bad := &JSONSchema{Ref: "#/$defs/missing"}
+			_, ok = bad.ValidateValue(1).(SchemaErrors)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
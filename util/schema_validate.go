@@ -0,0 +1,485 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v1"
+)
+
+// SchemaError is a single violation of a JSON Schema, located by the JSON
+// Pointer of the offending node and, when validating a document, the line
+// and column it starts at.
+type SchemaError struct {
+	Pointer string
+	Line    int
+	Column  int
+	Keyword string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	pointer := e.Pointer
+	if pointer == "" {
+		pointer = "(root)"
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d, column %d: %s: %s", e.Line, e.Column, pointer, e.Message)
+	}
+	return pointer + ": " + e.Message
+}
+
+// SchemaErrors holds every violation found, in document order.
+type SchemaErrors []*SchemaError
+
+func (e SchemaErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateJSON validates the JSON document data against s. Violations are
+// returned as SchemaErrors with the line and column of each offending node;
+// other errors, such as syntax errors or unresolvable $refs, are returned
+// as is.
+func (s *JSONSchema) ValidateJSON(data []byte) error {
+	v, positions, _, err := parseJSONPositions(data)
+	if err != nil {
+		return err
+	}
+	return s.validate(v, positions)
+}
+
+// ValidateYAML is ValidateJSON for YAML documents. Lines and columns are
+// reported for block style nodes; nodes within flow collections report the
+// position of the collection.
+func (s *JSONSchema) ValidateYAML(data []byte) error {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	positions, _ := yamlPositions(data)
+	return s.validate(v, positions)
+}
+
+// ValidateValue validates generic data, such as the result of unmarshaling
+// JSON or YAML into an interface{}, against s.
+func (s *JSONSchema) ValidateValue(v interface{}) error {
+	return s.validate(v, nil)
+}
+
+func (s *JSONSchema) validate(v interface{}, positions docPositions) error {
+	c := &schemaChecker{root: s, visiting: map[schemaVisit]bool{}}
+	if err := c.check(s, normalizeJSONValue(v), ""); err != nil {
+		return err
+	}
+	if len(c.errs) == 0 {
+		return nil
+	}
+	for _, e := range c.errs {
+		if pos, ok := positions.find(e.Pointer); ok {
+			e.Line, e.Column = pos.Line, pos.Column
+		}
+	}
+	sort.SliceStable(c.errs, func(i, j int) bool {
+		a, b := c.errs[i], c.errs[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return c.errs
+}
+
+// normalizeJSONValue converts generic values to the types encoding/json
+// produces: map[string]interface{}, []interface{} and float64.
+func normalizeJSONValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, elem := range val {
+			m[k] = normalizeJSONValue(elem)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, elem := range val {
+			m[fmt.Sprint(k)] = normalizeJSONValue(elem)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(val))
+		for i, elem := range val {
+			s[i] = normalizeJSONValue(elem)
+		}
+		return s
+	case json.Number:
+		f, _ := val.Float64()
+		return f
+	}
+
+	rv := reflect.ValueOf(v)
+	switch {
+	case !rv.IsValid():
+		return nil
+	case isIntKind(rv.Kind()):
+		return float64(rv.Int())
+	case isUintKind(rv.Kind()):
+		return float64(rv.Uint())
+	case isFloatKind(rv.Kind()):
+		return rv.Float()
+	}
+	return v
+}
+
+type schemaVisit struct {
+	schema  *JSONSchema
+	pointer string
+}
+
+type schemaChecker struct {
+	root *JSONSchema
+	errs SchemaErrors
+	// $refs being followed for a node, so that cyclic references end
+	visiting map[schemaVisit]bool
+}
+
+func (c *schemaChecker) fail(pointer, keyword, format string, args ...interface{}) {
+	c.errs = append(c.errs, &SchemaError{Pointer: pointer, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+}
+
+// matches reports whether v is valid against s, without recording violations.
+func (c *schemaChecker) matches(s *JSONSchema, v interface{}, pointer string) (bool, error) {
+	n := len(c.errs)
+	err := c.check(s, v, pointer)
+	ok := len(c.errs) == n
+	c.errs = c.errs[:n]
+	return ok, err
+}
+
+func (c *schemaChecker) check(s *JSONSchema, v interface{}, pointer string) error {
+	if s == nil {
+		return nil
+	}
+	if s.boolean != nil {
+		if !*s.boolean {
+			c.fail(pointer, "false", "is not allowed")
+		}
+		return nil
+	}
+
+	if s.Ref != "" {
+		target, err := c.resolve(s.Ref)
+		if err != nil {
+			return err
+		}
+		visit := schemaVisit{target, pointer}
+		if !c.visiting[visit] {
+			c.visiting[visit] = true
+			err = c.check(target, v, pointer)
+			delete(c.visiting, visit)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(s.Type) > 0 {
+		actual := jsonTypeOf(v)
+		ok := false
+		for _, t := range s.Type {
+			if t == actual || t == "number" && actual == "integer" {
+				ok = true
+			}
+		}
+		if !ok {
+			c.fail(pointer, "type", "must be of type %s, not %s", strings.Join(s.Type, " or "), actual)
+			// the remaining keywords would only repeat the complaint
+			return nil
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, option := range s.Enum {
+			if reflect.DeepEqual(normalizeJSONValue(option), v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			options := make([]string, len(s.Enum))
+			for i, option := range s.Enum {
+				options[i] = jsonString(option)
+			}
+			c.fail(pointer, "enum", "must be one of %s", strings.Join(options, ", "))
+		}
+	}
+	if len(s.Const) > 0 {
+		var constant interface{}
+		if err := json.Unmarshal(s.Const, &constant); err != nil {
+			return fmt.Errorf("invalid const: %v", err)
+		}
+		if !reflect.DeepEqual(normalizeJSONValue(constant), v) {
+			c.fail(pointer, "const", "must be %s", string(s.Const))
+		}
+	}
+
+	var err error
+	switch val := v.(type) {
+	case float64:
+		c.checkNumber(s, val, pointer)
+	case string:
+		err = c.checkString(s, val, pointer)
+	case []interface{}:
+		err = c.checkArray(s, val, pointer)
+	case map[string]interface{}:
+		err = c.checkObject(s, val, pointer)
+	}
+	if err != nil {
+		return err
+	}
+	return c.checkCombinators(s, v, pointer)
+}
+
+func (c *schemaChecker) checkNumber(s *JSONSchema, f float64, pointer string) {
+	switch {
+	case s.Minimum != nil && f < *s.Minimum:
+		c.fail(pointer, "minimum", "must be at least %s", formatFloat(*s.Minimum))
+	case s.ExclusiveMinimum != nil && f <= *s.ExclusiveMinimum:
+		c.fail(pointer, "exclusiveMinimum", "must be greater than %s", formatFloat(*s.ExclusiveMinimum))
+	}
+	switch {
+	case s.Maximum != nil && f > *s.Maximum:
+		c.fail(pointer, "maximum", "must be at most %s", formatFloat(*s.Maximum))
+	case s.ExclusiveMaximum != nil && f >= *s.ExclusiveMaximum:
+		c.fail(pointer, "exclusiveMaximum", "must be less than %s", formatFloat(*s.ExclusiveMaximum))
+	}
+	if s.MultipleOf != nil && *s.MultipleOf > 0 {
+		if q := f / *s.MultipleOf; q != math.Trunc(q) {
+			c.fail(pointer, "multipleOf", "must be a multiple of %s", formatFloat(*s.MultipleOf))
+		}
+	}
+}
+
+func (c *schemaChecker) checkString(s *JSONSchema, str string, pointer string) error {
+	n := utf8.RuneCountInString(str)
+	if s.MinLength != nil && n < *s.MinLength {
+		c.fail(pointer, "minLength", "must have length at least %d", *s.MinLength)
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		c.fail(pointer, "maxLength", "must have length at most %d", *s.MaxLength)
+	}
+	if s.Pattern != "" {
+		cached, ok := regexCache.Load(s.Pattern)
+		if !ok {
+			re, err := regexp.Compile(s.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern %q: %v", s.Pattern, err)
+			}
+			cached, _ = regexCache.LoadOrStore(s.Pattern, re)
+		}
+		if !cached.(*regexp.Regexp).MatchString(str) {
+			c.fail(pointer, "pattern", "must match %s", s.Pattern)
+		}
+	}
+	return nil
+}
+
+func (c *schemaChecker) checkArray(s *JSONSchema, items []interface{}, pointer string) error {
+	if s.MinItems != nil && len(items) < *s.MinItems {
+		c.fail(pointer, "minItems", "must have at least %d items", *s.MinItems)
+	}
+	if s.MaxItems != nil && len(items) > *s.MaxItems {
+		c.fail(pointer, "maxItems", "must have at most %d items", *s.MaxItems)
+	}
+	if s.UniqueItems {
+	unique:
+		for i := range items {
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(items[i], items[j]) {
+					c.fail(joinJSONPointer(pointer, strconv.Itoa(i)), "uniqueItems", "duplicates item %d", j)
+					break unique
+				}
+			}
+		}
+	}
+	if s.Items != nil {
+		for i, item := range items {
+			if err := c.check(s.Items, item, joinJSONPointer(pointer, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *schemaChecker) checkObject(s *JSONSchema, obj map[string]interface{}, pointer string) error {
+	if s.MinProperties != nil && len(obj) < *s.MinProperties {
+		c.fail(pointer, "minProperties", "must have at least %d properties", *s.MinProperties)
+	}
+	if s.MaxProperties != nil && len(obj) > *s.MaxProperties {
+		c.fail(pointer, "maxProperties", "must have at most %d properties", *s.MaxProperties)
+	}
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			c.fail(joinJSONPointer(pointer, name), "required", "is required")
+		}
+	}
+
+	for _, key := range sortedGenericKeys(obj) {
+		keyPointer := joinJSONPointer(pointer, key)
+		if prop, ok := s.Properties[key]; ok {
+			if err := c.check(prop, obj[key], keyPointer); err != nil {
+				return err
+			}
+			continue
+		}
+		if additional := s.AdditionalProperties; additional != nil {
+			if additional.boolean != nil && !*additional.boolean {
+				c.fail(keyPointer, "additionalProperties", "is not a known property")
+				continue
+			}
+			if err := c.check(additional, obj[key], keyPointer); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *schemaChecker) checkCombinators(s *JSONSchema, v interface{}, pointer string) error {
+	for _, sub := range s.AllOf {
+		if err := c.check(sub, v, pointer); err != nil {
+			return err
+		}
+	}
+	if len(s.AnyOf) > 0 {
+		found := false
+		for _, sub := range s.AnyOf {
+			ok, err := c.matches(sub, v, pointer)
+			if err != nil {
+				return err
+			}
+			if ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.fail(pointer, "anyOf", "must match at least one schema in anyOf")
+		}
+	}
+	if len(s.OneOf) > 0 {
+		matched := 0
+		for _, sub := range s.OneOf {
+			ok, err := c.matches(sub, v, pointer)
+			if err != nil {
+				return err
+			}
+			if ok {
+				matched++
+			}
+		}
+		if matched != 1 {
+			c.fail(pointer, "oneOf", "must match exactly one schema in oneOf, but matches %d", matched)
+		}
+	}
+	if s.Not != nil {
+		ok, err := c.matches(s.Not, v, pointer)
+		if err != nil {
+			return err
+		}
+		if ok {
+			c.fail(pointer, "not", "must not match the schema in not")
+		}
+	}
+	return nil
+}
+
+// resolve finds the subschema of the root schema that ref, a URI fragment
+// such as "#/$defs/Server", points to.
+func (c *schemaChecker) resolve(ref string) (*JSONSchema, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported $ref %q: only references within the schema are supported", ref)
+	}
+	fragment, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %v", ref, err)
+	}
+	segs, err := parseJSONPointer(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %v", ref, err)
+	}
+
+	s := c.root
+	for i := 0; i < len(segs) && s != nil; i++ {
+		// keywords holding a map or list of schemas are followed by a name or index
+		var next string
+		if i+1 < len(segs) {
+			next = segs[i+1]
+		}
+		switch segs[i] {
+		case "$defs":
+			s, i = s.Defs[next], i+1
+		case "properties":
+			s, i = s.Properties[next], i+1
+		case "allOf", "anyOf", "oneOf":
+			list := map[string][]*JSONSchema{"allOf": s.AllOf, "anyOf": s.AnyOf, "oneOf": s.OneOf}[segs[i]]
+			idx, err := strconv.Atoi(next)
+			if err != nil || idx < 0 || idx >= len(list) {
+				return nil, fmt.Errorf("unresolvable $ref %q", ref)
+			}
+			s, i = list[idx], i+1
+		case "items":
+			s = s.Items
+		case "additionalProperties":
+			s = s.AdditionalProperties
+		case "not":
+			s = s.Not
+		default:
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	if s == nil {
+		return nil, fmt.Errorf("unresolvable $ref %q", ref)
+	}
+	return s, nil
+}
+
+// jsonTypeOf returns the JSON Schema type of the normalized value v.
+func jsonTypeOf(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if val == math.Trunc(val) && !math.IsInf(val, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func joinJSONPointer(pointer, seg string) string {
+	return pointer + "/" + jsonPointerEscaper.Replace(seg)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
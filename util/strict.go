@@ -0,0 +1,292 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v1"
+)
+
+// UnknownFieldError reports a document key that matches no struct field.
+// Path is the key's full path in the document, e.g. "servers[0].lisen_port",
+// and Suggestion the closest field name, if any is close.
+type UnknownFieldError struct {
+	File       string
+	Line       int
+	Column     int
+	Path       string
+	Key        string
+	Suggestion string
+}
+
+func (e *UnknownFieldError) Error() string {
+	msg := fmt.Sprintf("unknown field %q at %s", e.Key, e.Path)
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", e.Suggestion)
+	}
+	if e.Line > 0 {
+		msg = fmt.Sprintf("%d:%d: %s", e.Line, e.Column, msg)
+	}
+	if e.File != "" {
+		msg = e.File + ":" + msg
+	}
+	return msg
+}
+
+// UnknownFieldErrors holds every unknown key of a document, in document order.
+type UnknownFieldErrors []*UnknownFieldError
+
+func (e UnknownFieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ReadJsonFromFileStrict is ReadJsonFromFile, except that keys matching no
+// field of result, at any depth, fail with UnknownFieldErrors instead of
+// being ignored. Duplicate keys are not errors; the last one wins.
+func ReadJsonFromFileStrict(filename string, result interface{}) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return unmarshalJSONStrict(filename, data, result)
+}
+
+// ReadYamlFromFileStrict is ReadYamlFromFile, except that keys matching no
+// field of result, at any depth, fail with UnknownFieldErrors instead of
+// being ignored. Duplicate keys are not errors; the last one wins.
+func ReadYamlFromFileStrict(filename string, result interface{}) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return unmarshalYAMLStrict(filename, data, result)
+}
+
+func unmarshalJSONStrict(filename string, data []byte, result interface{}) error {
+	v, _, keys, err := parseJSONPositions(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	if err := checkUnknownKeys(filename, v, reflect.TypeOf(result), keys, jsonKeyFields); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+func unmarshalYAMLStrict(filename string, data []byte, result interface{}) error {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	_, keys := yamlPositions(data)
+	if err := checkUnknownKeys(filename, v, reflect.TypeOf(result), keys, yamlKeyFields); err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, result)
+}
+
+// keyField is a struct field as named in documents.
+type keyField struct {
+	Name string
+	Type reflect.Type
+}
+
+// keyFields lists the fields of struct type t the way a decoder names them,
+// and whether keys must match their case exactly. anyKey is set if t
+// accepts every key, e.g. through an inlined map.
+type keyFields func(t reflect.Type) (fields []keyField, exactCase bool, anyKey bool)
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// jsonKeyFields names fields the way encoding/json does.
+func jsonKeyFields(t reflect.Type) ([]keyField, bool, bool) {
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return nil, false, true
+	}
+	var fields []keyField
+	for _, f := range taggedFields(t, "json") {
+		fields = append(fields, keyField{f.Name, f.Field.Type})
+	}
+	return fields, false, false
+}
+
+var yamlSetterType = reflect.TypeOf((*yaml.Setter)(nil)).Elem()
+
+// yamlKeyFields names fields the way gopkg.in/yaml.v1 does: by their
+// lower-cased name unless tagged, with only ",inline" fields inlined.
+func yamlKeyFields(t reflect.Type) ([]keyField, bool, bool) {
+	if reflect.PtrTo(t).Implements(yamlSetterType) {
+		return nil, true, true
+	}
+	var fields []keyField
+	anyKey := false
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		// yaml.v1 skips unexported fields, embedded ones included
+		if sf.PkgPath != "" {
+			continue
+		}
+		name, opts := parseTag(sf.Tag.Get("yaml"))
+		if name == "-" {
+			continue
+		}
+		if containsOption(opts, "inline") {
+			switch sf.Type.Kind() {
+			case reflect.Struct:
+				inlined, _, any := yamlKeyFields(sf.Type)
+				fields = append(fields, inlined...)
+				anyKey = anyKey || any
+			case reflect.Map:
+				anyKey = true
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+		fields = append(fields, keyField{name, sf.Type})
+	}
+	return fields, true, anyKey
+}
+
+// checkUnknownKeys reports the keys of the generic document v that the
+// decoder described by fieldsOf would ignore when decoding into type t.
+func checkUnknownKeys(filename string, v interface{}, t reflect.Type, keys docPositions, fieldsOf keyFields) error {
+	c := &unknownKeyChecker{file: filename, keys: keys, fieldsOf: fieldsOf}
+	c.check(v, t, "", "")
+	if len(c.errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(c.errs, func(i, j int) bool {
+		a, b := c.errs[i], c.errs[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return c.errs
+}
+
+type unknownKeyChecker struct {
+	file     string
+	keys     docPositions
+	fieldsOf keyFields
+	errs     UnknownFieldErrors
+}
+
+func (c *unknownKeyChecker) check(v interface{}, t reflect.Type, pointer, path string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if isLeafType(t) {
+		return
+	}
+
+	switch doc := normalizeJSONValue(v).(type) {
+	case map[string]interface{}:
+		var fields []keyField
+		exactCase := false
+		switch t.Kind() {
+		case reflect.Struct:
+			var anyKey bool
+			if fields, exactCase, anyKey = c.fieldsOf(t); anyKey {
+				return
+			}
+		case reflect.Map:
+		default:
+			return
+		}
+		for _, key := range sortedGenericKeys(doc) {
+			keyPointer, keyPath := joinJSONPointer(pointer, key), joinField(path, key)
+			elemType := t
+			if t.Kind() == reflect.Map {
+				elemType, keyPath = t.Elem(), joinKey(path, key)
+			} else if field, ok := matchKeyField(fields, key, exactCase); ok {
+				elemType = field.Type
+			} else {
+				c.unknown(key, keyPointer, keyPath, fields)
+				continue
+			}
+			c.check(doc[key], elemType, keyPointer, keyPath)
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return
+		}
+		for i, elem := range doc {
+			c.check(elem, t.Elem(), pointer+"/"+strconv.Itoa(i), joinIndex(path, i))
+		}
+	}
+}
+
+func matchKeyField(fields []keyField, key string, exactCase bool) (keyField, bool) {
+	for _, f := range fields {
+		if f.Name == key {
+			return f, true
+		}
+	}
+	if !exactCase {
+		for _, f := range fields {
+			if strings.EqualFold(f.Name, key) {
+				return f, true
+			}
+		}
+	}
+	return keyField{}, false
+}
+
+func (c *unknownKeyChecker) unknown(key, pointer, path string, fields []keyField) {
+	err := &UnknownFieldError{File: c.file, Path: path, Key: key}
+	if pos, ok := c.keys[pointer]; ok {
+		err.Line, err.Column = pos.Line, pos.Column
+	}
+
+	best := -1
+	for _, f := range fields {
+		d := editDistance(strings.ToLower(key), strings.ToLower(f.Name))
+		// only suggest names that differ by a typo or two
+		if d <= 2 || d <= len(key)/3 {
+			if best < 0 || d < best {
+				best, err.Suggestion = d, f.Name
+			}
+		}
+	}
+	c.errs = append(c.errs, err)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(first int, rest ...int) int {
+	for _, n := range rest {
+		if n < first {
+			first = n
+		}
+	}
+	return first
+}
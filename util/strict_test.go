@@ -0,0 +1,127 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type strictListener struct {
+	ListenPort int    `json:"listen_port" yaml:"listen_port"`
+	Host       string `json:"host" yaml:"host"`
+}
+
+type strictBase struct {
+	Version int `yaml:"version"`
+}
+
+type strictEmbedded struct {
+	strictBase `yaml:",inline"`
+	Name       string `yaml:"name"`
+}
+
+type strictConfig struct {
+	Name    string            `json:"name" yaml:"name"`
+	Servers []strictListener  `json:"servers" yaml:"servers"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+}
+
+func TestStrictDecoding(t *testing.T) {
+	Convey("When decoding JSON strictly", t, func() {
+		Convey("Known keys at any depth should decode", func() {
+			var c strictConfig
+			err := unmarshalJSONStrict("app.json", []byte(`{"NAME": "app", "servers": [{"listen_port": 80}], "labels": {"any": "x"}}`), &c)
+			So(err, ShouldBeNil)
+			So(c.Name, ShouldEqual, "app")
+			So(c.Servers[0].ListenPort, ShouldEqual, 80)
+		})
+
+		Convey("Unknown keys should be reported with their position and a suggestion", func() {
+			var c strictConfig
+			data := "{\n  \"name\": \"app\",\n  \"servers\": [\n    {\"lisen_port\": 80, \"zzz\": 1}\n  ]\n}"
+			err := unmarshalJSONStrict("app.json", []byte(data), &c)
+			So(err, ShouldHaveSameTypeAs, UnknownFieldErrors{})
+			errs := err.(UnknownFieldErrors)
+			So(len(errs), ShouldEqual, 2)
+			So(errs[0].Error(), ShouldEqual, `app.json:4:6: unknown field "lisen_port" at servers[0].lisen_port, did you mean "listen_port"?`)
+			So(errs[1].Key, ShouldEqual, "zzz")
+			So(errs[1].Suggestion, ShouldEqual, "")
+			So(errs[1].Column, ShouldEqual, 24)
+		})
+	})
+
+	Convey("When decoding YAML strictly", t, func() {
+		Convey("Unknown keys should be reported with their position and a suggestion", func() {
+			var c strictConfig
+			data := "name: app\nservers:\n  - host: a\n    listenport: 80\n"
+			err := unmarshalYAMLStrict("app.yaml", []byte(data), &c)
+			So(err, ShouldNotBeNil)
+			errs := err.(UnknownFieldErrors)
+			So(len(errs), ShouldEqual, 1)
+			So(errs[0].Error(), ShouldEqual, `app.yaml:4:5: unknown field "listenport" at servers[0].listenport, did you mean "listen_port"?`)
+		})
+
+		Convey("Keys should match case exactly", func() {
+			var c strictConfig
+			err := unmarshalYAMLStrict("app.yaml", []byte("Name: app\n"), &c)
+			So(err, ShouldNotBeNil)
+			So(err.(UnknownFieldErrors)[0].Suggestion, ShouldEqual, "name")
+		})
+
+		Convey("Fields of unexported embedded structs should be unknown", func() {
+			var c strictEmbedded
+			err := unmarshalYAMLStrict("app.yaml", []byte("name: app\nversion: 2\n"), &c)
+			So(err, ShouldNotBeNil)
+			So(err.(UnknownFieldErrors)[0].Key, ShouldEqual, "version")
+		})
+	})
+}
+
+func TestReadFromFileStrict(t *testing.T) {
+	Convey("With JSON and YAML config files", t, func() {
+		dir, err := ioutil.TempDir("", "strict")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		write := func(name, content string) string {
+			path := filepath.Join(dir, name)
+			So(ioutil.WriteFile(path, []byte(content), 0644), ShouldBeNil)
+			return path
+		}
+
+		Convey("Clean files should be read", func() {
+			var j, y strictConfig
+			So(ReadJsonFromFileStrict(write("clean.json", `{"name": "app", "servers": [{"host": "a"}]}`), &j), ShouldBeNil)
+			So(ReadYamlFromFileStrict(write("clean.yaml", "name: app\nservers:\n  - host: a\n"), &y), ShouldBeNil)
+			So(j, ShouldResemble, strictConfig{Name: "app", Servers: []strictListener{{Host: "a"}}})
+			So(y, ShouldResemble, j)
+		})
+		Convey("Unknown fields should fail with the file's name and position", func() {
+			var c strictConfig
+			path := write("unknown.json", "{\n  \"nmae\": \"app\"\n}")
+			err := ReadJsonFromFileStrict(path, &c)
+			So(err, ShouldHaveSameTypeAs, UnknownFieldErrors{})
+			So(err.Error(), ShouldEqual, path+`:2:3: unknown field "nmae" at nmae, did you mean "name"?`)
+			So(c.Name, ShouldEqual, "")
+
+			path = write("unknown.yaml", "name: app\nport: 80\n")
+			err = ReadYamlFromFileStrict(path, &c)
+			So(err, ShouldHaveSameTypeAs, UnknownFieldErrors{})
+			So(err.(UnknownFieldErrors)[0].Line, ShouldEqual, 2)
+		})
+		Convey("Duplicate keys should be accepted, the last one winning", func() {
+			var j, y strictConfig
+			So(ReadJsonFromFileStrict(write("dup.json", `{"name": "a", "name": "b"}`), &j), ShouldBeNil)
+			So(ReadYamlFromFileStrict(write("dup.yaml", "name: a\nname: b\n"), &y), ShouldBeNil)
+			So(j.Name, ShouldEqual, "b")
+			So(y.Name, ShouldEqual, "b")
+		})
+		Convey("Missing files should fail", func() {
+			var c strictConfig
+			So(ReadJsonFromFileStrict(filepath.Join(dir, "none.json"), &c), ShouldNotBeNil)
+			So(ReadYamlFromFileStrict(filepath.Join(dir, "none.yaml"), &c), ShouldNotBeNil)
+		})
+	})
+}
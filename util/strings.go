@@ -58,3 +58,31 @@ func DateAsString(when time.Time, layout string) string {
 func Sprintln(message string, args ...interface{}) {
 	fmt.Println(fmt.Sprintf(message, args...))
 }
+
+// splitWords breaks an identifier such as "HTTPServerPort2" into its words
+// ("HTTP", "Server", "Port2").
+func splitWords(s string) []string {
+	var words []string
+	runes := []rune(s)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		switch {
+		case cur == '_' || cur == '-':
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+		case unicode.IsUpper(cur) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			words = append(words, string(runes[start:i]))
+			start = i
+		case unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
@@ -50,7 +50,7 @@ func SetNestedStructIndex(key string, x reflect.Value, v reflect.Value) {
 		// nestedObj is a nil ptr to struct
 		if TypeIsPtrToStruct(StructFieldType(keyBeforeDot, obj)) && !nestedObj.Elem().IsValid() {
 			structField, _ := StructIndirect(obj).Type().FieldByName(keyBeforeDot)
-			nestedObj.Set(reflect.Indirect(reflect.New(structField.Type)))
+			nestedObj.Set(reflect.New(structField.Type.Elem()))
 		}
 		obj = nestedObj
 	}
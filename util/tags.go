@@ -0,0 +1,133 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// taggedField is a struct field as seen by an encoder that names fields
+// through a struct tag, such as encoding/json.
+type taggedField struct {
+	Name    string
+	Index   []int
+	Field   reflect.StructField
+	Options []string
+}
+
+func (f taggedField) hasOption(option string) bool {
+	return containsOption(f.Options, option)
+}
+
+func containsOption(opts []string, option string) bool {
+	for _, o := range opts {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+type taggedFieldsKey struct {
+	t   reflect.Type
+	tag string
+}
+
+var taggedFieldsCache sync.Map
+
+// taggedFields lists the exported fields of struct type t named by tag, the
+// way encoding/json does: fields tagged "-" are skipped, untagged fields use
+// their Go name, and untagged embedded structs, as well as struct fields with
+// the "squash" option, have their fields inlined unless a shallower field
+// already claimed the name.
+func taggedFields(t reflect.Type, tag string) []taggedField {
+	key := taggedFieldsKey{t, tag}
+	if cached, ok := taggedFieldsCache.Load(key); ok {
+		return cached.([]taggedField)
+	}
+
+	var fields []taggedField
+	seen := map[string]bool{}
+	level := []taggedField{{Index: nil, Field: reflect.StructField{Type: t}}}
+	visited := map[reflect.Type]bool{}
+	for len(level) > 0 {
+		var next []taggedField
+		claimed := map[string]bool{}
+		for _, parent := range level {
+			pt := parent.Field.Type
+			if pt.Kind() == reflect.Ptr {
+				pt = pt.Elem()
+			}
+			if visited[pt] {
+				continue
+			}
+			visited[pt] = true
+
+			for i := 0; i < pt.NumField(); i++ {
+				sf := pt.Field(i)
+				name, opts := parseTag(sf.Tag.Get(tag))
+				if name == "-" && len(opts) == 0 {
+					continue
+				}
+				index := append(append([]int{}, parent.Index...), i)
+
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				squash := sf.Anonymous && name == "" || sf.PkgPath == "" && containsOption(opts, "squash")
+				if squash && ft.Kind() == reflect.Struct {
+					next = append(next, taggedField{Index: index, Field: sf})
+					continue
+				}
+				if sf.PkgPath != "" {
+					continue
+				}
+				if name == "" {
+					name = sf.Name
+				}
+				if seen[name] {
+					continue
+				}
+				claimed[name] = true
+				sf.Index = index
+				fields = append(fields, taggedField{Name: name, Index: index, Field: sf, Options: opts})
+			}
+		}
+		for name := range claimed {
+			seen[name] = true
+		}
+		level = next
+	}
+
+	taggedFieldsCache.Store(key, fields)
+	return fields
+}
+
+// parseTag splits a tag such as `json:"name,omitempty"` into its name and options.
+func parseTag(tag string) (string, []string) {
+	if tag == "" {
+		return "", nil
+	}
+	parts := strings.Split(tag, ",")
+	return parts[0], parts[1:]
+}
+
+// fieldByTag returns a fieldLookup resolving names through tag, falling back
+// to a case-insensitive match like encoding/json does.
+func fieldByTag(tag string) fieldLookup {
+	return func(t reflect.Type, name string) (reflect.StructField, bool) {
+		fields := taggedFields(t, tag)
+		for _, f := range fields {
+			if f.Name == name {
+				return f.Field, true
+			}
+		}
+		for _, f := range fields {
+			if strings.EqualFold(f.Name, name) {
+				return f.Field, true
+			}
+		}
+		return reflect.StructField{}, false
+	}
+}
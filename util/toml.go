@@ -0,0 +1,863 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ReadTomlFromFile reads the TOML 1.0 document in filename into result.
+// Fields are matched by their `toml` tags as Decode matches them, local
+// date-times and dates are read in time.Local and local times as times on
+// year 0.
+func ReadTomlFromFile(filename string, result interface{}) error {
+	rawFileData, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return unmarshalToml(rawFileData, result)
+}
+
+// WriteTomlToFile writes data, a struct or map, to filename as TOML. Nested
+// structs and maps become tables and slices of them arrays of tables, with
+// the keys of each table sorted. Nil values are left out, as TOML has no
+// null.
+func WriteTomlToFile(filename string, data interface{}, perm os.FileMode) error {
+	rawData, err := marshalToml(data)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, rawData, perm)
+}
+
+type tomlCodec struct{}
+
+func (tomlCodec) Unmarshal(data []byte, v interface{}) error {
+	return unmarshalToml(data, v)
+}
+
+func (tomlCodec) Marshal(v interface{}) ([]byte, error) {
+	return marshalToml(v)
+}
+
+func (tomlCodec) Detect(data []byte) bool {
+	m, err := parseToml(data)
+	return err == nil && len(m) > 0
+}
+
+func unmarshalToml(data []byte, result interface{}) error {
+	m, err := parseToml(data)
+	if err != nil {
+		return err
+	}
+	return Decode(m, result, DecodeOptions{TagName: "toml"})
+}
+
+// tomlError is a syntax error in a TOML document.
+type tomlError struct {
+	pos docPosition
+	msg string
+}
+
+func (e *tomlError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.pos.Line, e.pos.Column, e.msg)
+}
+
+// tomlTableKind records how a table was defined, which decides how it may be
+// extended.
+type tomlTableKind int
+
+const (
+	// created as the parent of a [header], it may be defined later
+	tomlImplicit tomlTableKind = iota
+	// defined by a [header] or [[header]]
+	tomlHeader
+	// created by a dotted key, it may only be extended by dotted keys
+	tomlDotted
+	// an inline table, which is complete
+	tomlInline
+)
+
+type tomlArrayKey struct {
+	table uintptr
+	key   string
+}
+
+// parseToml parses a TOML document into maps, []interface{}, string, int64,
+// float64, bool and time.Time values.
+func parseToml(data []byte) (m map[string]interface{}, err error) {
+	p := &tomlParser{
+		src:         string(data),
+		lines:       newLineIndex(data),
+		root:        map[string]interface{}{},
+		kinds:       map[uintptr]tomlTableKind{},
+		tableArrays: map[tomlArrayKey]bool{},
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(*tomlError)
+			if !ok {
+				panic(r)
+			}
+			m, err = nil, perr
+		}
+	}()
+	p.document()
+	return p.root, nil
+}
+
+type tomlParser struct {
+	src     string
+	pos     int
+	lines   *lineIndex
+	root    map[string]interface{}
+	current map[string]interface{}
+	// tables by identity, as their keys depend on where they are reached from
+	kinds       map[uintptr]tomlTableKind
+	tableArrays map[tomlArrayKey]bool
+}
+
+func tableID(t map[string]interface{}) uintptr {
+	return reflect.ValueOf(t).Pointer()
+}
+
+func (p *tomlParser) failAt(offset int, format string, args ...interface{}) {
+	panic(&tomlError{p.lines.position(offset), fmt.Sprintf(format, args...)})
+}
+
+func (p *tomlParser) fail(format string, args ...interface{}) {
+	p.failAt(p.pos, format, args...)
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) rest() string {
+	return p.src[p.pos:]
+}
+
+// found describes the next character for error messages.
+func (p *tomlParser) found() string {
+	if p.eof() {
+		return "end of file"
+	}
+	r, _ := utf8.DecodeRuneInString(p.rest())
+	return strconv.QuoteRune(r)
+}
+
+func (p *tomlParser) consume(s string) bool {
+	if strings.HasPrefix(p.rest(), s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) newline() bool {
+	return p.consume("\n") || p.consume("\r\n")
+}
+
+func (p *tomlParser) comment() {
+	if !p.consume("#") {
+		return
+	}
+	for !p.eof() && p.src[p.pos] != '\n' && !strings.HasPrefix(p.rest(), "\r\n") {
+		if c := p.src[p.pos]; c < 0x20 && c != '\t' || c == 0x7f {
+			p.fail("control character %U in comment", rune(c))
+		}
+		p.pos++
+	}
+}
+
+// skipBlank skips whitespace, comments and newlines.
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.comment()
+		if !p.newline() {
+			return
+		}
+	}
+}
+
+func (p *tomlParser) endOfLine() {
+	p.skipSpace()
+	p.comment()
+	if !p.eof() && !p.newline() {
+		p.fail("expected a newline, found %s", p.found())
+	}
+}
+
+func (p *tomlParser) document() {
+	p.consume("\ufeff")
+	p.current = p.root
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return
+		}
+		if strings.HasPrefix(p.rest(), "[") {
+			p.header()
+		} else {
+			p.keyValue(p.current)
+		}
+		p.endOfLine()
+	}
+}
+
+// header parses a [table] or [[array.of.tables]] header and makes its table
+// the current one.
+func (p *tomlParser) header() {
+	start := p.pos
+	closing := "]"
+	if p.consume("[[") {
+		closing = "]]"
+	} else {
+		p.consume("[")
+	}
+	key := p.key()
+	if !p.consume(closing) {
+		p.fail("expected %q, found %s", closing, p.found())
+	}
+
+	t := p.root
+	for i, k := range key[:len(key)-1] {
+		switch v := t[k].(type) {
+		case nil:
+			t[k] = p.newTable(tomlImplicit)
+			t = t[k].(map[string]interface{})
+		case map[string]interface{}:
+			if p.kinds[tableID(v)] == tomlInline {
+				p.failAt(start, "cannot extend inline table %s", strings.Join(key[:i+1], "."))
+			}
+			t = v
+		case []interface{}:
+			if !p.tableArrays[tomlArrayKey{tableID(t), k}] {
+				p.failAt(start, "key %s is not a table", strings.Join(key[:i+1], "."))
+			}
+			t = v[len(v)-1].(map[string]interface{})
+		default:
+			p.failAt(start, "key %s is not a table", strings.Join(key[:i+1], "."))
+		}
+	}
+
+	last := key[len(key)-1]
+	existing, exists := t[last]
+	if closing == "]]" {
+		next := p.newTable(tomlHeader)
+		switch {
+		case !exists:
+			t[last] = []interface{}{next}
+			p.tableArrays[tomlArrayKey{tableID(t), last}] = true
+		case p.tableArrays[tomlArrayKey{tableID(t), last}]:
+			t[last] = append(existing.([]interface{}), next)
+		default:
+			p.failAt(start, "key %s is already defined", strings.Join(key, "."))
+		}
+		p.current = next
+		return
+	}
+	if !exists {
+		p.current = p.newTable(tomlHeader)
+		t[last] = p.current
+		return
+	}
+	if sub, ok := existing.(map[string]interface{}); ok && p.kinds[tableID(sub)] == tomlImplicit {
+		p.kinds[tableID(sub)] = tomlHeader
+		p.current = sub
+		return
+	}
+	p.failAt(start, "table %s is already defined", strings.Join(key, "."))
+}
+
+func (p *tomlParser) newTable(kind tomlTableKind) map[string]interface{} {
+	t := map[string]interface{}{}
+	p.kinds[tableID(t)] = kind
+	return t
+}
+
+// keyValue parses a `key = value` pair into t.
+func (p *tomlParser) keyValue(t map[string]interface{}) {
+	start := p.pos
+	key := p.key()
+	if !p.consume("=") {
+		p.fail("expected '=' after key, found %s", p.found())
+	}
+	p.skipSpace()
+	value := p.value()
+
+	for i, k := range key[:len(key)-1] {
+		switch v := t[k].(type) {
+		case nil:
+			t[k] = p.newTable(tomlDotted)
+			t = t[k].(map[string]interface{})
+		case map[string]interface{}:
+			if p.kinds[tableID(v)] != tomlDotted {
+				p.failAt(start, "cannot extend table %s with a dotted key", strings.Join(key[:i+1], "."))
+			}
+			t = v
+		default:
+			p.failAt(start, "key %s is already defined", strings.Join(key[:i+1], "."))
+		}
+	}
+	last := key[len(key)-1]
+	if _, exists := t[last]; exists {
+		p.failAt(start, "key %s is already defined", strings.Join(key, "."))
+	}
+	t[last] = value
+}
+
+// key parses a possibly dotted key and the whitespace around it.
+func (p *tomlParser) key() []string {
+	var parts []string
+	for {
+		p.skipSpace()
+		switch {
+		case strings.HasPrefix(p.rest(), `"""`), strings.HasPrefix(p.rest(), "'''"):
+			p.fail("multi-line strings cannot be keys")
+		case strings.HasPrefix(p.rest(), `"`):
+			parts = append(parts, p.basicString())
+		case strings.HasPrefix(p.rest(), "'"):
+			parts = append(parts, p.literalString())
+		default:
+			start := p.pos
+			for !p.eof() && isTomlBareKeyChar(p.src[p.pos]) {
+				p.pos++
+			}
+			if p.pos == start {
+				p.fail("expected a key, found %s", p.found())
+			}
+			parts = append(parts, p.src[start:p.pos])
+		}
+		p.skipSpace()
+		if !p.consume(".") {
+			return parts
+		}
+	}
+}
+
+func isTomlBareKeyChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) value() interface{} {
+	switch {
+	case p.eof():
+		p.fail("expected a value, found end of file")
+	case strings.HasPrefix(p.rest(), `"""`):
+		return p.multilineString(`"""`)
+	case strings.HasPrefix(p.rest(), `"`):
+		return p.basicString()
+	case strings.HasPrefix(p.rest(), "'''"):
+		return p.multilineString("'''")
+	case strings.HasPrefix(p.rest(), "'"):
+		return p.literalString()
+	case strings.HasPrefix(p.rest(), "["):
+		return p.array()
+	case strings.HasPrefix(p.rest(), "{"):
+		return p.inlineTable()
+	}
+	return p.scalar()
+}
+
+func (p *tomlParser) array() []interface{} {
+	p.pos++
+	arr := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.consume("]") {
+			return arr
+		}
+		arr = append(arr, p.value())
+		p.skipBlank()
+		if p.consume("]") {
+			return arr
+		}
+		if !p.consume(",") {
+			p.fail("expected ',' or ']' in array, found %s", p.found())
+		}
+	}
+}
+
+func (p *tomlParser) inlineTable() map[string]interface{} {
+	p.pos++
+	t := map[string]interface{}{}
+	p.skipSpace()
+	if !p.consume("}") {
+		for {
+			p.keyValue(t)
+			p.skipSpace()
+			if p.consume("}") {
+				break
+			}
+			if !p.consume(",") {
+				p.fail("expected ',' or '}' in inline table, found %s", p.found())
+			}
+		}
+	}
+	p.freeze(t)
+	return t
+}
+
+// freeze marks t, and the tables dotted keys created within it, inline.
+func (p *tomlParser) freeze(t map[string]interface{}) {
+	p.kinds[tableID(t)] = tomlInline
+	for _, v := range t {
+		if sub, ok := v.(map[string]interface{}); ok {
+			p.freeze(sub)
+		}
+	}
+}
+
+func (p *tomlParser) basicString() string {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.src[p.pos] == '\n' || p.src[p.pos] == '\r' {
+			p.fail("unterminated string")
+		}
+		switch c := p.src[p.pos]; {
+		case c == '"':
+			p.pos++
+			return b.String()
+		case c == '\\':
+			p.escape(&b)
+		case c < 0x20 && c != '\t' || c == 0x7f:
+			p.fail("control character %U in string", rune(c))
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) escape(b *strings.Builder) {
+	p.pos++
+	if p.eof() {
+		p.fail("unterminated string")
+	}
+	c := p.src[p.pos]
+	if r, ok := tomlEscapes[c]; ok {
+		b.WriteByte(r)
+		p.pos++
+		return
+	}
+	digits := 0
+	switch c {
+	case 'u':
+		digits = 4
+	case 'U':
+		digits = 8
+	default:
+		p.fail("invalid escape sequence \\%c", c)
+	}
+	if p.pos+1+digits > len(p.src) {
+		p.fail("invalid escape sequence \\%c", c)
+	}
+	hex := p.src[p.pos+1 : p.pos+1+digits]
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(n)) {
+		p.fail("invalid unicode escape \\%c%s", c, hex)
+	}
+	b.WriteRune(rune(n))
+	p.pos += 1 + digits
+}
+
+var tomlEscapes = map[byte]byte{'b': '\b', 't': '\t', 'n': '\n', 'f': '\f', 'r': '\r', '"': '"', '\\': '\\'}
+
+func (p *tomlParser) literalString() string {
+	p.pos++
+	start := p.pos
+	for {
+		if p.eof() || p.src[p.pos] == '\n' || p.src[p.pos] == '\r' {
+			p.fail("unterminated string")
+		}
+		switch c := p.src[p.pos]; {
+		case c == '\'':
+			p.pos++
+			return p.src[start : p.pos-1]
+		case c < 0x20 && c != '\t' || c == 0x7f:
+			p.fail("control character %U in string", rune(c))
+		}
+		p.pos++
+	}
+}
+
+// multilineString parses a multi-line basic or literal string, whichever
+// delim opens. Only basic strings have escapes.
+func (p *tomlParser) multilineString(delim string) string {
+	p.pos += len(delim)
+	// a newline right after the opening delimiter is trimmed
+	p.newline()
+	var b strings.Builder
+	for {
+		if p.eof() {
+			p.fail("unterminated string")
+		}
+		if strings.HasPrefix(p.rest(), delim) {
+			// up to two quotes may precede the closing delimiter
+			n := 0
+			for p.pos+n < len(p.src) && p.src[p.pos+n] == delim[0] {
+				n++
+			}
+			if n > 5 {
+				p.fail("too many quotes closing string")
+			}
+			b.WriteString(p.src[p.pos : p.pos+n-3])
+			p.pos += n
+			return b.String()
+		}
+		switch c := p.src[p.pos]; {
+		case c == '\\' && delim == `"""`:
+			// a backslash ending a line trims the whitespace that follows
+			end := p.pos + 1
+			for end < len(p.src) && (p.src[end] == ' ' || p.src[end] == '\t') {
+				end++
+			}
+			if rest := p.src[end:]; strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+				p.pos = end
+				for !p.eof() && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+					p.pos++
+				}
+				continue
+			}
+			p.escape(&b)
+		case c == '\r' && strings.HasPrefix(p.rest(), "\r\n"), c == '\n', c == '\t':
+			b.WriteByte(c)
+			p.pos++
+		case c < 0x20 || c == 0x7f:
+			p.fail("control character %U in string", rune(c))
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+var (
+	tomlIntRe      = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)$`)
+	tomlHexRe      = regexp.MustCompile(`^0x[0-9A-Fa-f](_?[0-9A-Fa-f])*$`)
+	tomlOctRe      = regexp.MustCompile(`^0o[0-7](_?[0-7])*$`)
+	tomlBinRe      = regexp.MustCompile(`^0b[01](_?[01])*$`)
+	tomlFloatRe    = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][+-]?[0-9](_?[0-9])*)?$`)
+	tomlDateTimeRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})?)?$`)
+	tomlTimeRe     = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(\.\d+)?$`)
+)
+
+// scalar parses a boolean, number or date-time.
+func (p *tomlParser) scalar() interface{} {
+	start := p.pos
+	tok := p.token()
+	if len(tok) == 10 && tomlDateTimeRe.MatchString(tok) && strings.HasPrefix(p.rest(), " ") &&
+		len(p.rest()) > 1 && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9' {
+		// a space may separate the date and time
+		p.pos++
+		tok += "T" + p.token()
+	}
+	if tok == "" {
+		p.fail("expected a value, found %s", p.found())
+	}
+
+	switch tok {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "inf", "+inf":
+		return math.Inf(1)
+	case "-inf":
+		return math.Inf(-1)
+	case "nan", "+nan", "-nan":
+		return math.NaN()
+	}
+
+	if upper := strings.ToUpper(tok); tomlDateTimeRe.MatchString(upper) || tomlTimeRe.MatchString(upper) {
+		t, err := parseTomlDateTime(upper)
+		if err != nil {
+			p.failAt(start, "invalid date-time %q", tok)
+		}
+		return t
+	}
+
+	base, digits := 10, tok
+	switch {
+	case tomlIntRe.MatchString(tok):
+	case tomlHexRe.MatchString(tok):
+		base, digits = 16, tok[2:]
+	case tomlOctRe.MatchString(tok):
+		base, digits = 8, tok[2:]
+	case tomlBinRe.MatchString(tok):
+		base, digits = 2, tok[2:]
+	case tomlFloatRe.MatchString(tok):
+		f, err := strconv.ParseFloat(strings.Replace(tok, "_", "", -1), 64)
+		if err != nil {
+			p.failAt(start, "float %s is out of range", tok)
+		}
+		return f
+	default:
+		p.failAt(start, "invalid value %q", tok)
+	}
+	n, err := strconv.ParseInt(strings.Replace(digits, "_", "", -1), base, 64)
+	if err != nil {
+		p.failAt(start, "integer %s is out of range", tok)
+	}
+	return n
+}
+
+// token consumes the characters up to the next delimiter.
+func (p *tomlParser) token() string {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\r\n,]}#", p.src[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// parseTomlDateTime parses an upper-cased offset or local date-time, local
+// date or local time.
+func parseTomlDateTime(s string) (time.Time, error) {
+	if tomlTimeRe.MatchString(s) {
+		return time.Parse("15:04:05", s)
+	}
+	m := tomlDateTimeRe.FindStringSubmatch(s)
+	switch {
+	case m[3] != "":
+		return time.Parse(time.RFC3339, s)
+	case m[1] != "":
+		return time.ParseInLocation("2006-01-02T15:04:05", s, time.Local)
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+// marshalToml encodes the struct or map v as a TOML document.
+func marshalToml(v interface{}) ([]byte, error) {
+	encoded, err := encodeValue(reflect.ValueOf(v), "toml")
+	if err != nil {
+		return nil, err
+	}
+	table, ok := encoded.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot encode %T as TOML, it is not a struct or map", v)
+	}
+	w := &tomlWriter{}
+	if err := w.table(nil, table); err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+type tomlWriter struct {
+	buf bytes.Buffer
+}
+
+// table writes the keys of t, then its tables and arrays of tables under
+// headers prefixed with path.
+func (w *tomlWriter) table(path []string, t map[string]interface{}) error {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var tables, arrays []string
+	for _, k := range keys {
+		v := t[k]
+		if _, ok := v.(map[string]interface{}); ok {
+			tables = append(tables, k)
+			continue
+		}
+		if s, ok := v.([]interface{}); ok && isTomlTableArray(s) {
+			arrays = append(arrays, k)
+			continue
+		}
+		if v == nil {
+			continue
+		}
+		value, err := formatTomlValue(v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", joinTomlKey(append(path, k)), err)
+		}
+		fmt.Fprintf(&w.buf, "%s = %s\n", formatTomlKey(k), value)
+	}
+
+	for _, k := range tables {
+		sub := append(path[:len(path):len(path)], k)
+		w.header("[" + joinTomlKey(sub) + "]")
+		if err := w.table(sub, t[k].(map[string]interface{})); err != nil {
+			return err
+		}
+	}
+	for _, k := range arrays {
+		sub := append(path[:len(path):len(path)], k)
+		for _, elem := range t[k].([]interface{}) {
+			w.header("[[" + joinTomlKey(sub) + "]]")
+			if err := w.table(sub, elem.(map[string]interface{})); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *tomlWriter) header(h string) {
+	if w.buf.Len() > 0 {
+		w.buf.WriteByte('\n')
+	}
+	w.buf.WriteString(h + "\n")
+}
+
+// isTomlTableArray reports whether s is written as an array of tables.
+func isTomlTableArray(s []interface{}) bool {
+	for _, elem := range s {
+		if _, ok := elem.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
+func formatTomlKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for i := 0; i < len(k); i++ {
+		if !isTomlBareKeyChar(k[i]) {
+			return quoteTomlString(k)
+		}
+	}
+	return k
+}
+
+func joinTomlKey(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = formatTomlKey(k)
+	}
+	return strings.Join(keys, ".")
+}
+
+// formatTomlValue formats v, one of the values encodeValue returns, as an
+// inline TOML value.
+func formatTomlValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", fmt.Errorf("cannot encode nil as TOML")
+	case string:
+		return quoteTomlString(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		if v.Year() == 0 && v.YearDay() == 1 {
+			return v.Format("15:04:05.999999999"), nil
+		}
+		return v.Format(time.RFC3339Nano), nil
+	case float32:
+		return formatTomlFloat(float64(v), 32), nil
+	case float64:
+		return formatTomlFloat(v, 64), nil
+	case []interface{}:
+		elems := make([]string, len(v))
+		for i, elem := range v {
+			s, err := formatTomlValue(elem)
+			if err != nil {
+				return "", fmt.Errorf("[%d]: %v", i, err)
+			}
+			elems[i] = s
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var pairs []string
+		for _, k := range keys {
+			if v[k] == nil {
+				continue
+			}
+			s, err := formatTomlValue(v[k])
+			if err != nil {
+				return "", fmt.Errorf("%s: %v", k, err)
+			}
+			pairs = append(pairs, formatTomlKey(k)+" = "+s)
+		}
+		if len(pairs) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(pairs, ", ") + " }", nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch {
+	case isIntKind(rv.Kind()):
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case isUintKind(rv.Kind()):
+		if rv.Uint() > math.MaxInt64 {
+			return "", fmt.Errorf("%d overflows a TOML integer", rv.Uint())
+		}
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("cannot encode %T as TOML", v)
+}
+
+func formatTomlFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(s, ".e") {
+		// keep it a float when read back
+		s += ".0"
+	}
+	return s
+}
+
+func quoteTomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\b':
+			b.WriteString(`\b`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
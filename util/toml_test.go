@@ -0,0 +1,192 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"math"
+	"testing"
+	"time"
+)
+
+const tomlDocument = `# a comment
+title = "TOML \"Example\"" # trailing comment
+"quoted key" = 'C:\path'
+site."google.com" = true
+numbers = [ 0xf_f, 0xff, 0o17, 0b101, -1_000, +3.5e2, inf, 6.626e-34, ]
+multi = """
+Roses \
+   are red"""
+literal = '''
+two
+lines'''
+
+[owner]
+name = "Tom"
+dob = 1979-05-27T07:32:00-08:00
+local = 1979-05-27 07:32:00.5
+day = 1979-05-27
+lunch = 12:30:00
+
+[database]
+ports = [8000, 8001]
+nested = [[1, 2], ["a"]]
+point = { x = 1, y.z = 2 }
+
+[servers.alpha]
+ip = "10.0.0.1"
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+
+[[products]]
+name = "Nail"
+[products.size]
+mm = 3
+`
+
+type tomlProduct struct {
+	Name string         `toml:"name"`
+	Size map[string]int `toml:"size,omitempty"`
+}
+
+type tomlConfig struct {
+	Title    string `toml:"title"`
+	Owner    tomlOwner
+	Products []tomlProduct `toml:"products"`
+	Timeout  time.Duration `toml:"timeout"`
+	Ratio    float64       `toml:"ratio"`
+	Ptr      *int          `toml:"ptr"`
+}
+
+type tomlOwner struct {
+	Name string    `toml:"name"`
+	DOB  time.Time `toml:"dob"`
+}
+
+func TestTomlParsing(t *testing.T) {
+	Convey("When parsing a TOML document", t, func() {
+		m, err := parseToml([]byte(tomlDocument))
+		So(err, ShouldBeNil)
+
+		Convey("Keys and strings should be read", func() {
+			So(m["title"], ShouldEqual, `TOML "Example"`)
+			So(m["quoted key"], ShouldEqual, `C:\path`)
+			So(m["site"], ShouldResemble, map[string]interface{}{"google.com": true})
+			So(m["multi"], ShouldEqual, "Roses are red")
+			So(m["literal"], ShouldEqual, "two\nlines")
+		})
+
+		Convey("Numbers should be read as int64 and float64", func() {
+			numbers := m["numbers"].([]interface{})
+			So(len(numbers), ShouldEqual, 8)
+			So(numbers[1:5], ShouldResemble, []interface{}{int64(255), int64(15), int64(5), int64(-1000)})
+			So(numbers[5], ShouldEqual, 350.0)
+			So(math.IsInf(numbers[6].(float64), 1), ShouldBeTrue)
+		})
+
+		Convey("Date-times should be read as times", func() {
+			owner := m["owner"].(map[string]interface{})
+			So(owner["dob"].(time.Time).Equal(time.Date(1979, 5, 27, 15, 32, 0, 0, time.UTC)), ShouldBeTrue)
+			So(owner["local"], ShouldResemble, time.Date(1979, 5, 27, 7, 32, 0, 500000000, time.Local))
+			So(owner["day"], ShouldResemble, time.Date(1979, 5, 27, 0, 0, 0, 0, time.Local))
+			So(owner["lunch"], ShouldResemble, time.Date(0, 1, 1, 12, 30, 0, 0, time.UTC))
+		})
+
+		Convey("Tables, inline tables and arrays of tables should be read", func() {
+			db := m["database"].(map[string]interface{})
+			So(db["nested"], ShouldResemble, []interface{}{[]interface{}{int64(1), int64(2)}, []interface{}{"a"}})
+			So(db["point"], ShouldResemble, map[string]interface{}{"x": int64(1), "y": map[string]interface{}{"z": int64(2)}})
+			So(m["servers"], ShouldResemble, map[string]interface{}{"alpha": map[string]interface{}{"ip": "10.0.0.1"}})
+			So(m["products"], ShouldResemble, []interface{}{
+				map[string]interface{}{"name": "Hammer"},
+				map[string]interface{}{},
+				map[string]interface{}{"name": "Nail", "size": map[string]interface{}{"mm": int64(3)}},
+			})
+		})
+	})
+
+	Convey("Invalid documents should fail with their position", t, func() {
+		for doc, msg := range map[string]string{
+			"a = 1\na = 2":                 "line 2, column 1: key a is already defined",
+			"[a]\nb = 1\n[a]":              "line 3, column 1: table a is already defined",
+			"a = {b = 1}\n[a.c]":           "line 2, column 1: cannot extend inline table a",
+			"[a]\nb.c = 1\n[a.b]":          "line 3, column 1: table a.b is already defined",
+			"a = 1 b = 2":                  "line 1, column 7: expected a newline, found 'b'",
+			"a = 01":                       `line 1, column 5: invalid value "01"`,
+			"a = \"x\nb = 1":               "line 1, column 7: unterminated string",
+			"a = \"\\q\"":                  `line 1, column 7: invalid escape sequence \q`,
+			"a = { b = 1, }":               "line 1, column 14: expected a key, found '}'",
+			"a = 1979-13-01":               `line 1, column 5: invalid date-time "1979-13-01"`,
+			"a = [1 2]":                    "line 1, column 8: expected ',' or ']' in array, found '2'",
+			"a = 9223372036854775808":      "line 1, column 5: integer 9223372036854775808 is out of range",
+			"x = [1]\n[[x]]":               "line 2, column 1: key x is already defined",
+			"[a]\nb = 1\n[a.b]":            "line 3, column 1: table a.b is already defined",
+			"[[a]]\n[a]":                   "line 2, column 1: table a is already defined",
+			"a.b = 1\n[a.c]\n[a]":          "line 3, column 1: table a is already defined",
+			"[x.y]\n[x]\ny.z = 1":          "line 3, column 1: cannot extend table y with a dotted key",
+			"a = 0x_ff":                    `line 1, column 5: invalid value "0x_ff"`,
+			"a = \"\"\"\nunterminated\n\"": "line 3, column 2: unterminated string",
+		} {
+			_, err := parseToml([]byte(doc))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, msg)
+		}
+	})
+}
+
+func TestTomlCodec(t *testing.T) {
+	Convey("When decoding TOML into a struct", t, func() {
+		var c tomlConfig
+		So(unmarshalToml([]byte("timeout = \"5s\"\nratio = 1\n"+tomlDocument), &c), ShouldBeNil)
+
+		Convey("Fields should be matched by tag and converted", func() {
+			So(c.Title, ShouldEqual, `TOML "Example"`)
+			So(c.Owner.Name, ShouldEqual, "Tom")
+			So(c.Owner.DOB.Year(), ShouldEqual, 1979)
+			So(len(c.Products), ShouldEqual, 3)
+			So(c.Products[2].Size["mm"], ShouldEqual, 3)
+		})
+
+		Convey("Encoding it should write it back in the same shape", func() {
+			data, err := marshalToml(c)
+			So(err, ShouldBeNil)
+			var back tomlConfig
+			So(unmarshalToml(data, &back), ShouldBeNil)
+			So(back.Owner.DOB.Equal(c.Owner.DOB), ShouldBeTrue)
+			back.Owner.DOB = c.Owner.DOB
+			So(back, ShouldResemble, c)
+		})
+	})
+
+	Convey("When encoding a map", t, func() {
+		data, err := marshalToml(map[string]interface{}{
+			"name":   "app",
+			"float":  2.0,
+			"weird":  map[string]interface{}{"a b": "x\ty", "list": []interface{}{map[string]interface{}{"k": 1}, 2}},
+			"nested": map[string]interface{}{"deep": map[string]interface{}{"on": true}},
+			"items":  []map[string]interface{}{{"id": 1}, {"id": 2}},
+			"none":   nil,
+		})
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, `float = 2.0
+name = "app"
+
+[nested]
+
+[nested.deep]
+on = true
+
+[weird]
+"a b" = "x\ty"
+list = [{ k = 1 }, 2]
+
+[[items]]
+id = 1
+
+[[items]]
+id = 2
+`)
+	})
+}
@@ -0,0 +1,410 @@
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError is a single violation found by Validate.
+type FieldError struct {
+	Path    string
+	Rule    string
+	Param   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors holds every violation found by Validate, in field order.
+type ValidationErrors []*FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidatorFunc checks v against a rule's parameter, returning an error
+// describing the violation, e.g. "must be a valid hostname".
+type ValidatorFunc func(v reflect.Value, param string) error
+
+var (
+	validatorsLock sync.RWMutex
+	validators     = map[string]ValidatorFunc{}
+)
+
+// RegisterValidator makes fn available to `validate` tags as name, e.g.
+// RegisterValidator("hostname", ...) for `validate:"hostname"`. It replaces
+// any validator previously registered under name, including built-in ones.
+func RegisterValidator(name string, fn ValidatorFunc) {
+	validatorsLock.Lock()
+	defer validatorsLock.Unlock()
+	validators[name] = fn
+}
+
+// invalidRuleError is returned by the built-in validators for a parameter or
+// field type a rule cannot work with, a mistake in the tag rather than in
+// the value.
+type invalidRuleError string
+
+func (e invalidRuleError) Error() string {
+	return string(e)
+}
+
+func lookupValidator(name string) (ValidatorFunc, bool) {
+	validatorsLock.RLock()
+	defer validatorsLock.RUnlock()
+	fn, ok := validators[name]
+	return fn, ok
+}
+
+func init() {
+	RegisterValidator("required", validateRequired)
+	RegisterValidator("min", validateMin)
+	RegisterValidator("max", validateMax)
+	RegisterValidator("len", validateLen)
+	RegisterValidator("oneof", validateOneOf)
+	RegisterValidator("regex", validateRegex)
+}
+
+// crossFieldRules compare a field against a sibling field named by the parameter.
+var crossFieldRules = map[string]struct {
+	accept  func(cmp int) bool
+	message string
+}{
+	"eqfield":  {func(c int) bool { return c == 0 }, "must be equal to"},
+	"nefield":  {func(c int) bool { return c != 0 }, "must not be equal to"},
+	"gtfield":  {func(c int) bool { return c > 0 }, "must be greater than"},
+	"gtefield": {func(c int) bool { return c >= 0 }, "must be greater than or equal to"},
+	"ltfield":  {func(c int) bool { return c < 0 }, "must be less than"},
+	"ltefield": {func(c int) bool { return c <= 0 }, "must be less than or equal to"},
+}
+
+// Validate checks obj against the `validate` tags of its fields, recursing
+// through nested structs, pointers, slices and maps. A tag is a comma
+// separated list of rules:
+//
+//	required        must not be a zero value, nil or empty
+//	omitempty       skip the remaining rules if the value is zero
+//	min=N, max=N    bounds for numbers and durations, or for the length of
+//	                strings, slices and maps
+//	len=N           exact length
+//	oneof=a|b|c     the value must print as one of the options
+//	regex=EXPR      strings must match EXPR; it must be the last rule, as
+//	                EXPR may itself contain commas
+//	gtfield=F       cross-field comparisons against sibling field F, also
+//	                gtefield, ltfield, ltefield, eqfield and nefield; they
+//	                are skipped if either field is nil
+//
+// plus any rule added with RegisterValidator. Violations are returned
+// together as ValidationErrors, keyed by their full path. Mistakes in the
+// tags themselves, such as unknown rules, bad parameters and rules that do
+// not apply to the field's type, are returned as plain errors instead.
+func Validate(obj interface{}) error {
+	v := &validator{guard: newCycleGuard()}
+	if err := v.validate("", reflect.ValueOf(obj)); err != nil {
+		return err
+	}
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+type validateRule struct {
+	name  string
+	param string
+}
+
+// parseValidateTag splits a `validate` tag into its rules.
+func parseValidateTag(tag string) []validateRule {
+	var rules []validateRule
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "regex=") {
+			part, tag = tag, ""
+		} else if i := strings.IndexByte(tag, ','); i >= 0 {
+			part, tag = tag[:i], tag[i+1:]
+		} else {
+			part, tag = tag, ""
+		}
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, param := part, ""
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			name, param = part[:i], part[i+1:]
+		}
+		rules = append(rules, validateRule{name, param})
+	}
+	return rules
+}
+
+type validator struct {
+	errs  ValidationErrors
+	guard cycleGuard
+}
+
+func (v *validator) validate(path string, val reflect.Value) error {
+	val, leave, ok := v.guard.enter(val)
+	if !ok {
+		return nil
+	}
+	defer leave()
+
+	switch val.Kind() {
+	case reflect.Struct:
+		t := val.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			fieldPath := joinField(path, field.Name)
+			if err := v.checkField(fieldPath, field, val.Field(i), val); err != nil {
+				return err
+			}
+			if err := v.validate(fieldPath, val.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			if err := v.validate(joinIndex(path, i), val.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range sortedMapKeys(val) {
+			if err := v.validate(joinKey(path, key.Interface()), val.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkField applies the rules of field's tag to fv, whose struct is parent.
+func (v *validator) checkField(path string, field reflect.StructField, fv, parent reflect.Value) error {
+	for _, rule := range parseValidateTag(field.Tag.Get("validate")) {
+		if rule.name == "omitempty" {
+			if isEmptyValue(fv) {
+				return nil
+			}
+			continue
+		}
+
+		if cross, ok := crossFieldRules[rule.name]; ok {
+			other, ok := parent.Type().FieldByName(rule.param)
+			if !ok {
+				return fmt.Errorf("%s: %s refers to unknown field %q", path, rule.name, rule.param)
+			}
+			a, b := diffIndirect(fv), diffIndirect(parent.FieldByIndex(other.Index))
+			if !a.IsValid() || !b.IsValid() {
+				// as with min and max, nil is left to required
+				continue
+			}
+			cmp, err := compareValues(a, b)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %v", path, rule.name, err)
+			}
+			if !cross.accept(cmp) {
+				v.fail(path, rule, cross.message+" "+rule.param)
+			}
+			continue
+		}
+
+		fn, ok := lookupValidator(rule.name)
+		if !ok {
+			return fmt.Errorf("%s: unknown validation rule %q", path, rule.name)
+		}
+		if err := fn(fv, rule.param); err != nil {
+			if _, ok := err.(invalidRuleError); ok {
+				return fmt.Errorf("%s: %s: %v", path, rule.name, err)
+			}
+			v.fail(path, rule, err.Error())
+			if rule.name == "required" {
+				// the remaining rules would only repeat the same complaint
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+func (v *validator) fail(path string, rule validateRule, msg string) {
+	v.errs = append(v.errs, &FieldError{Path: path, Rule: rule.name, Param: rule.param, Message: msg})
+}
+
+func validateRequired(v reflect.Value, _ string) error {
+	if isEmptyValue(v) {
+		return fmt.Errorf("is required")
+	}
+	return nil
+}
+
+func validateMin(v reflect.Value, param string) error {
+	return validateBound(v, param, func(c int) bool { return c >= 0 }, "at least")
+}
+
+func validateMax(v reflect.Value, param string) error {
+	return validateBound(v, param, func(c int) bool { return c <= 0 }, "at most")
+}
+
+func validateLen(v reflect.Value, param string) error {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		return invalidRuleError(fmt.Sprintf("invalid len parameter %q", param))
+	}
+	if l, ok := valueLen(v); !ok || l != n {
+		return fmt.Errorf("must have length %d", n)
+	}
+	return nil
+}
+
+// validateBound checks a number, duration or length of v against param.
+func validateBound(v reflect.Value, param string, accept func(int) bool, desc string) error {
+	v = diffIndirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+
+	if l, ok := valueLen(v); ok {
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return invalidRuleError(fmt.Sprintf("invalid length parameter %q", param))
+		}
+		if !accept(compareOrdered(l, n)) {
+			return fmt.Errorf("must have length %s %d", desc, n)
+		}
+		return nil
+	}
+
+	bound, err := DefaultStringCoercer.Coerce(param, v.Type())
+	if err != nil {
+		return invalidRuleError(fmt.Sprintf("invalid parameter %q for %s", param, v.Type()))
+	}
+	cmp, err := compareValues(v, bound)
+	if err != nil {
+		return invalidRuleError(err.Error())
+	}
+	if !accept(cmp) {
+		return fmt.Errorf("must be %s %s", desc, param)
+	}
+	return nil
+}
+
+func validateOneOf(v reflect.Value, param string) error {
+	v = diffIndirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	options := strings.Split(param, "|")
+	s := fmt.Sprint(v.Interface())
+	for _, option := range options {
+		if s == option {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(options, ", "))
+}
+
+var regexCache sync.Map
+
+func validateRegex(v reflect.Value, param string) error {
+	v = diffIndirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Kind() != reflect.String {
+		return invalidRuleError(fmt.Sprintf("regex only applies to strings, not %s", v.Type()))
+	}
+
+	cached, ok := regexCache.Load(param)
+	if !ok {
+		re, err := regexp.Compile(param)
+		if err != nil {
+			return invalidRuleError(fmt.Sprintf("invalid regex %q: %v", param, err))
+		}
+		cached, _ = regexCache.LoadOrStore(param, re)
+	}
+	if !cached.(*regexp.Regexp).MatchString(v.String()) {
+		return fmt.Errorf("must match %s", param)
+	}
+	return nil
+}
+
+// valueLen returns the length of strings (in runes), slices, arrays and maps.
+func valueLen(v reflect.Value) (int, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+// compareValues orders two numbers, strings, durations or times of the same
+// kind, returning -1, 0 or 1.
+func compareValues(a, b reflect.Value) (int, error) {
+	a, b = diffIndirect(a), diffIndirect(b)
+	if !a.IsValid() || !b.IsValid() {
+		return 0, fmt.Errorf("cannot compare nil values")
+	}
+	if a.Type() == timeType && b.Type() == timeType {
+		ta, tb := a.Interface().(time.Time), b.Interface().(time.Time)
+		switch {
+		case ta.Before(tb):
+			return -1, nil
+		case ta.After(tb):
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	switch {
+	case isIntKind(a.Kind()) && isIntKind(b.Kind()):
+		return compareOrdered(a.Int(), b.Int()), nil
+	case isUintKind(a.Kind()) && isUintKind(b.Kind()):
+		return compareOrdered(a.Uint(), b.Uint()), nil
+	case isFloatKind(a.Kind()) && isFloatKind(b.Kind()):
+		return compareOrdered(a.Float(), b.Float()), nil
+	case a.Kind() == reflect.String && b.Kind() == reflect.String:
+		return strings.Compare(a.String(), b.String()), nil
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", a.Type(), b.Type())
+}
+
+func compareOrdered[T int | int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+func isFloatKind(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
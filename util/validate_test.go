@@ -0,0 +1,137 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type validateListener struct {
+	Host string `validate:"required,regex=^[a-z]+(\\.[a-z]{2,3})?$"`
+	Port int    `validate:"min=1,max=65535"`
+}
+
+type validateConfig struct {
+	Name      string             `validate:"required,max=8"`
+	Mode      string             `validate:"oneof=dev|prod"`
+	Listeners []validateListener `validate:"min=1"`
+	Primary   *validateListener
+	Timeout   time.Duration `validate:"min=1s"`
+	Start     int
+	End       int    `validate:"gtfield=Start"`
+	Email     string `validate:"omitempty,contains=@"`
+	Tags      map[string]validateListener
+}
+
+func TestValidate(t *testing.T) {
+	RegisterValidator("contains", func(v reflect.Value, param string) error {
+		if !strings.Contains(v.String(), param) {
+			return errors.New("must contain " + param)
+		}
+		return nil
+	})
+
+	Convey("When calling Validate", t, func() {
+		valid := func() *validateConfig {
+			return &validateConfig{
+				Name:      "app",
+				Mode:      "dev",
+				Listeners: []validateListener{{"example.com", 80}},
+				Timeout:   time.Minute,
+				Start:     1,
+				End:       2,
+			}
+		}
+
+		Convey("A valid struct should have no errors", func() {
+			So(Validate(valid()), ShouldBeNil)
+		})
+		Convey("Every violation should be reported with its full path", func() {
+			cfg := valid()
+			cfg.Name = "much-too-long"
+			cfg.Mode = "test"
+			cfg.Listeners = append(cfg.Listeners, validateListener{"", 0}, validateListener{"Bad_Host", 70000})
+			cfg.Primary = &validateListener{"ok", 1}
+			cfg.Timeout = time.Millisecond
+			cfg.End = 1
+			cfg.Email = "nope"
+			cfg.Tags = map[string]validateListener{"x": {"ok", -1}}
+
+			err := Validate(cfg)
+			So(err, ShouldNotBeNil)
+			errs := err.(ValidationErrors)
+			paths := []string{}
+			for _, e := range errs {
+				paths = append(paths, e.Path+" "+e.Rule)
+			}
+			So(paths, ShouldResemble, []string{
+				"Name max",
+				"Mode oneof",
+				"Listeners[1].Host required",
+				"Listeners[1].Port min",
+				"Listeners[2].Host regex",
+				"Listeners[2].Port max",
+				"Timeout min",
+				"End gtfield",
+				"Email contains",
+				"Tags[x].Port min",
+			})
+			So(errs[0].Error(), ShouldEqual, "Name: must have length at most 8")
+			So(errs[7].Error(), ShouldEqual, "End: must be greater than Start")
+		})
+		Convey("Length rules should apply to slices", func() {
+			cfg := valid()
+			cfg.Listeners = nil
+			So(Validate(cfg).Error(), ShouldEqual, "Listeners: must have length at least 1")
+		})
+		Convey("Cross-field rules should skip nil and compare integers exactly", func() {
+			type window struct {
+				From *int
+				To   *int `validate:"gtefield=From"`
+				Min  int64
+				Max  int64 `validate:"gtfield=Min"`
+				Lo   uint64
+				Hi   uint64 `validate:"gtfield=Lo"`
+			}
+			to := 1
+			So(Validate(window{To: &to, Min: 1 << 53, Max: 1<<53 + 1, Lo: 1 << 63, Hi: 1<<63 + 1}), ShouldBeNil)
+			err := Validate(window{Min: 1<<53 + 1, Max: 1 << 53, Lo: 1<<63 + 1, Hi: 1 << 63})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "Max: must be greater than Min; Hi: must be greater than Lo")
+		})
+		Convey("Unknown rules and bad parameters should be reported as plain errors", func() {
+			for _, bad := range []interface{}{
+				struct {
+					X int `validate:"frobnicate"`
+				}{},
+				struct {
+					X int `validate:"min=abc"`
+				}{},
+				struct {
+					X string `validate:"len=x"`
+				}{},
+				struct {
+					X string `validate:"regex=("`
+				}{},
+				struct {
+					X int `validate:"regex=^1$"`
+				}{},
+				struct {
+					X bool `validate:"max=1"`
+				}{},
+			} {
+				err := Validate(bad)
+				So(err, ShouldNotBeNil)
+				_, isValidation := err.(ValidationErrors)
+				So(isValidation, ShouldBeFalse)
+			}
+			So(Validate(struct {
+				X int `validate:"min=abc"`
+			}{}).Error(), ShouldEqual, `X: min: invalid parameter "abc" for int`)
+		})
+	})
+}
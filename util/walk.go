@@ -0,0 +1,65 @@
+package util
+
+import (
+	"errors"
+	"reflect"
+)
+
+// WalkFunc is called by Walk for every value it visits. path uses the GetPath
+// syntax and is "" for the root. field is the struct field holding the value;
+// elements of slices, arrays and maps share the field of their container.
+//
+// Returning SkipSubtree stops Walk from descending into the value, and
+// returning StopWalk ends the walk without error. Any other error aborts the
+// walk and is returned by Walk.
+type WalkFunc func(path string, field reflect.StructField, v reflect.Value) error
+
+var (
+	SkipSubtree = errors.New("skip this subtree")
+	StopWalk    = errors.New("stop walking")
+)
+
+// Walk calls fn for obj and, depth first, for every exported field, slice or
+// array element and map entry beneath it, transparently following pointers
+// and interfaces. Map entries are visited in sorted key order. A pointer that
+// leads back to one of its own ancestors is visited but not descended into.
+func Walk(obj interface{}, fn WalkFunc) error {
+	w := &walker{fn: fn, guard: newCycleGuard()}
+	err := w.walk("", reflect.StructField{}, reflect.ValueOf(obj))
+	if err == StopWalk {
+		return nil
+	}
+	return err
+}
+
+type walker struct {
+	fn    WalkFunc
+	guard cycleGuard
+}
+
+func (w *walker) walk(path string, field reflect.StructField, v reflect.Value) error {
+	if err := w.fn(path, field, v); err != nil {
+		if err == SkipSubtree {
+			return nil
+		}
+		return err
+	}
+
+	elem, leave, ok := w.guard.enter(v)
+	if !ok {
+		return nil
+	}
+	defer leave()
+
+	var err error
+	eachChild(elem, path, func(childPath string, childField reflect.StructField, child reflect.Value) {
+		if err != nil {
+			return
+		}
+		if childField.Name == "" {
+			childField = field
+		}
+		err = w.walk(childPath, childField, child)
+	})
+	return err
+}
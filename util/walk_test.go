@@ -0,0 +1,79 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type walkNode struct {
+	Name     string `audit:"name"`
+	Tags     []string
+	Children map[string]*walkNode
+	Parent   *walkNode
+	hidden   int
+}
+
+func TestWalk(t *testing.T) {
+	Convey("When calling Walk", t, func() {
+		root := &walkNode{Name: "root", Tags: []string{"a", "b"}}
+		child := &walkNode{Name: "child", Parent: root}
+		root.Children = map[string]*walkNode{"c": child}
+
+		visited := func(fn WalkFunc) []string {
+			paths := []string{}
+			err := Walk(root, func(path string, field reflect.StructField, v reflect.Value) error {
+				paths = append(paths, path)
+				if fn != nil {
+					return fn(path, field, v)
+				}
+				return nil
+			})
+			So(err, ShouldBeNil)
+			return paths
+		}
+
+		Convey("Every exported value should be visited once, stopping at cycles", func() {
+			So(visited(nil), ShouldResemble, []string{
+				"", "Name", "Tags", "Tags[0]", "Tags[1]", "Children", "Children[c]",
+				"Children[c].Name", "Children[c].Tags", "Children[c].Children", "Children[c].Parent",
+				"Parent",
+			})
+		})
+		Convey("Elements should be passed the field of their container", func() {
+			var tagField reflect.StructField
+			Walk(root, func(path string, field reflect.StructField, v reflect.Value) error {
+				if path == "Tags[1]" {
+					tagField = field
+				}
+				return nil
+			})
+			So(tagField.Name, ShouldEqual, "Tags")
+		})
+		Convey("SkipSubtree should prune the current value", func() {
+			paths := visited(func(path string, field reflect.StructField, v reflect.Value) error {
+				if field.Name == "Children" || field.Name == "Tags" {
+					return SkipSubtree
+				}
+				return nil
+			})
+			So(paths, ShouldResemble, []string{"", "Name", "Tags", "Children", "Parent"})
+		})
+		Convey("StopWalk should end the walk without error", func() {
+			paths := visited(func(path string, field reflect.StructField, v reflect.Value) error {
+				if field.Tag.Get("audit") == "name" {
+					return StopWalk
+				}
+				return nil
+			})
+			So(paths, ShouldResemble, []string{"", "Name"})
+		})
+		Convey("Other errors should be returned", func() {
+			boom := errors.New("boom")
+			err := Walk(root, func(string, reflect.StructField, reflect.Value) error { return boom })
+			So(err, ShouldEqual, boom)
+		})
+	})
+}
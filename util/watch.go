@@ -0,0 +1,210 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Watched holds the configuration loaded from a file by ReadConfigFile and
+// reloads it when the file changes. Changes are detected by polling the
+// file's modification time and size, so they are noticed on every platform
+// and file system. A new value replaces the current one only if it parses
+// and passes Validate; otherwise the current value is kept.
+type Watched[T any] struct {
+	path string
+	// reloadMu serializes reloads, so subscribers see changes in order
+	reloadMu sync.Mutex
+
+	mu      sync.RWMutex
+	value   T
+	modTime time.Time
+	size    int64
+
+	subsMu      sync.Mutex
+	subscribers []func(old, new T, changed []string)
+	onError     func(error)
+	// changes waiting for the subscribers, and whether a Reload is
+	// delivering them
+	queue      []watchChange[T]
+	delivering bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type watchChange[T any] struct {
+	old, new T
+	changed  []string
+}
+
+// NewWatched loads path into a new T, after applying its `default` tags,
+// and returns an error if it cannot be read or is invalid.
+func NewWatched[T any](path string) (*Watched[T], error) {
+	w := &Watched[T]{path: path}
+	if _, err := w.Reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Get returns the current value. It is shared with other callers and must
+// not be modified.
+func (w *Watched[T]) Get() T {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.value
+}
+
+// Subscribe registers fn to be called after every reload that changes the
+// value, with the old and new values and the changed paths, as reported by
+// Diff. Changes are delivered one at a time, in order, with no lock held, so
+// fn may call Reload; a change it causes is delivered once fn returns. See
+// Reload for when a change is delivered by another goroutine.
+func (w *Watched[T]) Subscribe(fn func(old, new T, changed []string)) {
+	w.subsMu.Lock()
+	defer w.subsMu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// OnError registers fn to receive the errors of reloads started by Watch.
+func (w *Watched[T]) OnError(fn func(error)) {
+	w.subsMu.Lock()
+	defer w.subsMu.Unlock()
+	w.onError = fn
+}
+
+// Reload loads the file if it changed since it was last loaded, reporting
+// whether the value changed. A file that fails to load is not retried until
+// it changes again.
+//
+// Reload calls the subscribers before returning, unless another Reload,
+// on another goroutine or in a subscriber up the stack, is calling them at
+// the time. That Reload then delivers the change too, once it is done with
+// the earlier ones, and this one may return before the subscribers have
+// seen it.
+func (w *Watched[T]) Reload() (bool, error) {
+	changed, err := w.load()
+	if !changed {
+		return false, err
+	}
+	w.deliver()
+	return true, nil
+}
+
+// load reads the file if it changed, queueing the change for the subscribers
+// if the value changed too.
+func (w *Watched[T]) load() (bool, error) {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, err
+	}
+
+	w.mu.RLock()
+	unchanged := info.ModTime().Equal(w.modTime) && info.Size() == w.size
+	w.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	next := new(T)
+	err = ApplyDefaults(next)
+	if err == nil {
+		err = ReadConfigFile(w.path, next)
+	}
+	if err == nil {
+		err = Validate(next)
+	}
+
+	w.mu.Lock()
+	w.modTime, w.size = info.ModTime(), info.Size()
+	if err != nil {
+		w.mu.Unlock()
+		return false, fmt.Errorf("%s: %v", w.path, err)
+	}
+	old := w.value
+	w.value = *next
+	w.mu.Unlock()
+
+	changed := Diff(old, *next).Paths()
+	if len(changed) == 0 {
+		return false, nil
+	}
+	w.subsMu.Lock()
+	w.queue = append(w.queue, watchChange[T]{old, *next, changed})
+	w.subsMu.Unlock()
+	return true, nil
+}
+
+// deliver calls the subscribers with the queued changes, unless another call
+// is already doing so and will deliver them too.
+func (w *Watched[T]) deliver() {
+	w.subsMu.Lock()
+	if w.delivering {
+		w.subsMu.Unlock()
+		return
+	}
+	w.delivering = true
+	for len(w.queue) > 0 {
+		c := w.queue[0]
+		w.queue = w.queue[1:]
+		subscribers := append([]func(old, new T, changed []string){}, w.subscribers...)
+		w.subsMu.Unlock()
+		for _, fn := range subscribers {
+			fn(c.old, c.new, c.changed)
+		}
+		w.subsMu.Lock()
+	}
+	w.delivering = false
+	w.subsMu.Unlock()
+}
+
+// Watch starts polling the file every interval until Stop is called.
+// Calling Watch again while watching has no effect.
+func (w *Watched[T]) Watch(interval time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return
+	}
+	w.stop, w.done = make(chan struct{}), make(chan struct{})
+
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if _, err := w.Reload(); err != nil {
+					w.subsMu.Lock()
+					onError := w.onError
+					w.subsMu.Unlock()
+					if onError != nil {
+						onError(err)
+					}
+				}
+			}
+		}
+	}(w.stop, w.done)
+}
+
+// Stop stops watching and waits for a reload in progress to finish. As it
+// waits for the subscribers and OnError function that reload calls, they
+// must not call Stop themselves, other than in a new goroutine.
+func (w *Watched[T]) Stop() {
+	w.mu.Lock()
+	stop, done := w.stop, w.done
+	w.stop, w.done = nil, nil
+	w.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,103 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type watchConfig struct {
+	Name string `json:"name" validate:"required"`
+	Port int    `json:"port" default:"80"`
+}
+
+func TestWatched(t *testing.T) {
+	Convey("With a watched config file", t, func() {
+		dir, err := ioutil.TempDir("", "watch")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "config.json")
+		So(ioutil.WriteFile(path, []byte(`{"name": "a"}`), 0644), ShouldBeNil)
+
+		w, err := NewWatched[watchConfig](path)
+		So(err, ShouldBeNil)
+		So(w.Get(), ShouldResemble, watchConfig{"a", 80})
+
+		var changes [][]string
+		w.Subscribe(func(old, new watchConfig, changed []string) {
+			changes = append(changes, changed)
+		})
+
+		Convey("An unchanged file should not be reloaded", func() {
+			reloaded, err := w.Reload()
+			So(err, ShouldBeNil)
+			So(reloaded, ShouldBeFalse)
+			So(changes, ShouldBeEmpty)
+		})
+		Convey("A changed file should be reloaded and reported", func() {
+			So(ioutil.WriteFile(path, []byte(`{"name": "bb", "port": 81}`), 0644), ShouldBeNil)
+			reloaded, err := w.Reload()
+			So(err, ShouldBeNil)
+			So(reloaded, ShouldBeTrue)
+			So(w.Get(), ShouldResemble, watchConfig{"bb", 81})
+			So(changes, ShouldResemble, [][]string{{"Name", "Port"}})
+		})
+		Convey("An invalid file should keep the current value", func() {
+			So(ioutil.WriteFile(path, []byte(`{"name": ""}`), 0644), ShouldBeNil)
+			reloaded, err := w.Reload()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "is required")
+			So(reloaded, ShouldBeFalse)
+			So(w.Get(), ShouldResemble, watchConfig{"a", 80})
+			So(changes, ShouldBeEmpty)
+		})
+		Convey("Subscribers should be able to reload", func() {
+			w.Subscribe(func(old, new watchConfig, changed []string) {
+				if new.Name == "bb" {
+					So(ioutil.WriteFile(path, []byte(`{"name": "ccc"}`), 0644), ShouldBeNil)
+					reloaded, err := w.Reload()
+					So(err, ShouldBeNil)
+					So(reloaded, ShouldBeTrue)
+				}
+			})
+			So(ioutil.WriteFile(path, []byte(`{"name": "bb"}`), 0644), ShouldBeNil)
+			_, err := w.Reload()
+			So(err, ShouldBeNil)
+			So(w.Get().Name, ShouldEqual, "ccc")
+			So(changes, ShouldResemble, [][]string{{"Name"}, {"Name"}})
+		})
+		Convey("Watching should deliver changes and errors", func() {
+			delivered := make(chan watchConfig, 10)
+			errs := make(chan error, 10)
+			w.Subscribe(func(old, new watchConfig, changed []string) { delivered <- new })
+			w.OnError(func(err error) { errs <- err })
+			w.Watch(10 * time.Millisecond)
+			defer w.Stop()
+
+			So(ioutil.WriteFile(path, []byte(`{"name": "bb"}`), 0644), ShouldBeNil)
+			select {
+			case v := <-delivered:
+				So(v.Name, ShouldEqual, "bb")
+			case <-time.After(5 * time.Second):
+				So("no change delivered", ShouldBeEmpty)
+			}
+			So(ioutil.WriteFile(path, []byte(`{"name": "b`), 0644), ShouldBeNil)
+			select {
+			case err := <-errs:
+				So(err, ShouldNotBeNil)
+			case <-time.After(5 * time.Second):
+				So("no error delivered", ShouldBeEmpty)
+			}
+			So(w.Get().Name, ShouldEqual, "bb")
+		})
+	})
+
+	Convey("A missing file should fail to load", t, func() {
+		_, err := NewWatched[watchConfig](filepath.Join(os.TempDir(), "no-such-watched-config.json"))
+		So(err, ShouldNotBeNil)
+	})
+}
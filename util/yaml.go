@@ -1,9 +1,15 @@
 package util
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
 	"gopkg.in/yaml.v1"
+	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
 )
 
 func ReadYamlFromFile(filename string, result interface{}) error {
@@ -21,3 +27,141 @@ func WriteYamlToFile(filename string, data interface{}, perm os.FileMode) error
 	}
 	return ioutil.WriteFile(filename, rawData, perm)
 }
+
+// YamlDocument is one document of a multi-document YAML file.
+type YamlDocument struct {
+	// Raw holds the document without its "---" and "..." markers.
+	Raw []byte
+	// Line is the line of the file Raw starts on.
+	Line int
+}
+
+// Decode unmarshals the document into result.
+func (d *YamlDocument) Decode(result interface{}) error {
+	if err := yaml.Unmarshal(d.Raw, result); err != nil {
+		return fmt.Errorf("document at line %d: %v", d.Line, err)
+	}
+	return nil
+}
+
+// ReadYamlDocuments splits the YAML stream in filename into its documents,
+// which are separated by "---" lines and may end with "..." lines.
+// Documents holding nothing but comments are left out.
+func ReadYamlDocuments(filename string) ([]*YamlDocument, error) {
+	var docs []*YamlDocument
+	err := ReadYamlDocumentsFunc(filename, func(doc *YamlDocument) error {
+		docs = append(docs, doc)
+		return nil
+	})
+	return docs, err
+}
+
+// ReadYamlDocumentsInto decodes every document in filename into a new
+// element appended to the slice result points to.
+func ReadYamlDocumentsInto(filename string, result interface{}) error {
+	v := reflect.ValueOf(result)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("cannot read documents into %T, it is not a pointer to a slice", result)
+	}
+	slice := v.Elem()
+	return ReadYamlDocumentsFunc(filename, func(doc *YamlDocument) error {
+		elem := reflect.New(slice.Type().Elem())
+		if err := doc.Decode(elem.Interface()); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem.Elem()))
+		return nil
+	})
+}
+
+// ReadYamlDocumentsFunc calls fn with each document in filename as it is
+// read, so large streams need not fit in memory. It stops at the first
+// error fn returns.
+func ReadYamlDocumentsFunc(filename string, fn func(doc *YamlDocument) error) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return splitYamlDocuments(f, fn)
+}
+
+func splitYamlDocuments(r io.Reader, fn func(doc *YamlDocument) error) error {
+	br := bufio.NewReader(r)
+	var buf bytes.Buffer
+	start, lineNo := 1, 0
+	hasContent := false
+	flush := func() error {
+		defer buf.Reset()
+		if !hasContent {
+			return nil
+		}
+		hasContent = false
+		return fn(&YamlDocument{Raw: append([]byte(nil), buf.Bytes()...), Line: start})
+	}
+
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line != "" {
+			lineNo++
+			trimmed := strings.TrimRight(line, "\r\n")
+			switch {
+			case isYamlMarker(trimmed, "---"):
+				if err := flush(); err != nil {
+					return err
+				}
+				// content may follow the marker, as in "--- !!map"
+				if rest := strings.TrimLeft(trimmed[3:], " \t"); rest != "" && rest[0] != '#' {
+					buf.WriteString(rest + "\n")
+					start, hasContent = lineNo, true
+				}
+			case isYamlMarker(trimmed, "..."):
+				if err := flush(); err != nil {
+					return err
+				}
+			default:
+				if buf.Len() == 0 {
+					start = lineNo
+				}
+				buf.WriteString(line)
+				// directives such as "%YAML 1.1" only precede documents
+				if content := strings.TrimSpace(trimmed); content != "" && content[0] != '#' && trimmed[0] != '%' {
+					hasContent = true
+				}
+			}
+		}
+		if err == io.EOF {
+			return flush()
+		}
+	}
+}
+
+// isYamlMarker reports whether line is the document marker marker, alone or
+// followed by whitespace.
+func isYamlMarker(line, marker string) bool {
+	return line == marker || strings.HasPrefix(line, marker+" ") || strings.HasPrefix(line, marker+"\t")
+}
+
+// WriteYamlDocuments writes every element of the slice docs to filename as
+// one YAML stream, with documents separated by "---" lines.
+func WriteYamlDocuments(filename string, docs interface{}, perm os.FileMode) error {
+	v := reflect.ValueOf(docs)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return fmt.Errorf("cannot write %T as YAML documents, it is not a slice", docs)
+	}
+	var buf bytes.Buffer
+	for i := 0; i < v.Len(); i++ {
+		rawData, err := yaml.Marshal(v.Index(i).Interface())
+		if err != nil {
+			return fmt.Errorf("document %d: %v", i, err)
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(rawData)
+	}
+	return ioutil.WriteFile(filename, buf.Bytes(), perm)
+}
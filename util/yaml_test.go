@@ -0,0 +1,85 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const yamlStream = `# leading comment
+---
+kind: Service
+name: web
+---
+# only a comment
+--- 
+kind: Deployment
+name: api
+spec:
+  script: |
+    echo ---
+...
+%YAML 1.1
+--- {kind: Secret, name: key}
+`
+
+type yamlManifest struct {
+	Kind string `yaml:"kind"`
+	Name string `yaml:"name"`
+}
+
+func TestYamlDocuments(t *testing.T) {
+	Convey("With a multi-document YAML file", t, func() {
+		dir, err := ioutil.TempDir("", "yaml")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "manifests.yaml")
+		So(ioutil.WriteFile(path, []byte(yamlStream), 0644), ShouldBeNil)
+
+		Convey("Every document with content should be read with its line", func() {
+			docs, err := ReadYamlDocuments(path)
+			So(err, ShouldBeNil)
+			So(len(docs), ShouldEqual, 3)
+			So(string(docs[0].Raw), ShouldEqual, "kind: Service\nname: web\n")
+			So(docs[0].Line, ShouldEqual, 3)
+			So(docs[1].Line, ShouldEqual, 8)
+			So(string(docs[2].Raw), ShouldEqual, "{kind: Secret, name: key}\n")
+			So(docs[2].Line, ShouldEqual, 15)
+
+			var m map[string]interface{}
+			So(docs[1].Decode(&m), ShouldBeNil)
+			So(m["spec"], ShouldResemble, map[interface{}]interface{}{"script": "echo ---\n"})
+		})
+
+		Convey("Documents should decode into a slice", func() {
+			var manifests []yamlManifest
+			So(ReadYamlDocumentsInto(path, &manifests), ShouldBeNil)
+			So(manifests, ShouldResemble, []yamlManifest{{"Service", "web"}, {"Deployment", "api"}, {"Secret", "key"}})
+		})
+
+		Convey("The callback should stop at its first error", func() {
+			calls := 0
+			err := ReadYamlDocumentsFunc(path, func(doc *YamlDocument) error {
+				calls++
+				return os.ErrInvalid
+			})
+			So(err, ShouldEqual, os.ErrInvalid)
+			So(calls, ShouldEqual, 1)
+		})
+
+		Convey("Written documents should read back the same", func() {
+			out := filepath.Join(dir, "out.yaml")
+			written := []yamlManifest{{"A", "a"}, {"B", "b"}}
+			So(WriteYamlDocuments(out, written, 0644), ShouldBeNil)
+			data, _ := ioutil.ReadFile(out)
+			So(string(data), ShouldEqual, "kind: A\nname: a\n---\nkind: B\nname: b\n")
+
+			var read []yamlManifest
+			So(ReadYamlDocumentsInto(out, &read), ShouldBeNil)
+			So(read, ShouldResemble, written)
+		})
+	})
+}
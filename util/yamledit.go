@@ -0,0 +1,972 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v1"
+)
+
+// YamlEditor edits a YAML document while keeping everything outside the
+// edited values, comments, key order and formatting included, byte for byte.
+// Paths use the GetPath syntax, with sequence items addressed by index:
+// "servers[0].port" or `metadata.labels["app.kubernetes.io/name"]`.
+//
+// Block mappings and sequences are edited in place; new keys are appended
+// after the last entry of their mapping. Entries of flow collections ([a, b]
+// and {a: b}) are edited the same way, new ones going before the closing
+// bracket. Only the first document of a stream is edited.
+type YamlEditor struct {
+	data []byte
+}
+
+// LoadYamlEditor reads filename for editing.
+func LoadYamlEditor(filename string) (*YamlEditor, error) {
+	rawFileData, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewYamlEditor(rawFileData)
+}
+
+// NewYamlEditor returns an editor for the YAML document data.
+func NewYamlEditor(data []byte) (*YamlEditor, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return &YamlEditor{data: append([]byte(nil), data...)}, nil
+}
+
+// Bytes returns the edited document.
+func (e *YamlEditor) Bytes() []byte {
+	return append([]byte(nil), e.data...)
+}
+
+// Save writes the edited document to filename.
+func (e *YamlEditor) Save(filename string, perm os.FileMode) error {
+	return ioutil.WriteFile(filename, e.data, perm)
+}
+
+// Get returns the value at path, decoded as by yaml.Unmarshal into an
+// interface{}. Missing paths fail with a wrapped ErrPathNotFound.
+func (e *YamlEditor) Get(path string) (interface{}, error) {
+	segs, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(e.data, &doc); err != nil {
+		return nil, err
+	}
+	for i, seg := range segs {
+		var ok bool
+		switch v := doc.(type) {
+		case map[interface{}]interface{}:
+			var key interface{}
+			if key, ok = yamlMapKey(v, seg); ok {
+				doc = v[key]
+			}
+		case []interface{}:
+			var idx int
+			if idx, ok = yamlIndex(seg, len(v)); ok {
+				doc = v[idx]
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", strings.Join(segs[:i+1], "."), ErrPathNotFound)
+		}
+	}
+	return doc, nil
+}
+
+// Set sets the value at path, which is marshaled with yaml.Marshal. Missing
+// mappings along the path are created, and a sequence index one past the
+// end appends an item.
+func (e *YamlEditor) Set(path string, value interface{}) error {
+	segs, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	return e.edit(func() error {
+		if len(segs) == 0 {
+			return e.rewrite(value)
+		}
+		root := parseYamlTree(e.data)
+		if root == nil {
+			root = &yamlNode{kind: yamlMappingNode, end: len(e.data)}
+		}
+		if root.kind == yamlScalarNode {
+			return e.rewriteGeneric(func(doc interface{}) (interface{}, error) {
+				return setYamlGeneric(doc, segs, value)
+			})
+		}
+
+		node := root
+		for i, seg := range segs {
+			idx, found := node.find(seg)
+			if !found {
+				if node.kind == yamlSequenceNode {
+					if idx != len(node.entries) {
+						return fmt.Errorf("%s: index %s is out of range", strings.Join(segs[:i+1], "."), seg)
+					}
+					return e.appendItem(node, nestYamlValue(segs[i+1:], value))
+				}
+				return e.insertEntry(node, seg, nestYamlValue(segs[i+1:], value))
+			}
+			entry := node.entries[idx]
+			if i == len(segs)-1 {
+				return e.replaceValue(node, entry, value)
+			}
+			if entry.value == nil {
+				return e.editInline(node, entry, segs[i+1:], value, false)
+			}
+			node = entry.value
+		}
+		return nil
+	})
+}
+
+// Delete removes the value at path and its key or sequence item. A mapping or
+// sequence left empty becomes {} or [].
+func (e *YamlEditor) Delete(path string) error {
+	segs, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	if len(segs) == 0 {
+		return fmt.Errorf("cannot delete the document root")
+	}
+	return e.edit(func() error {
+		root := parseYamlTree(e.data)
+		if root == nil {
+			return fmt.Errorf("%s: %w", path, ErrPathNotFound)
+		}
+		if root.kind == yamlScalarNode {
+			return e.rewriteGeneric(func(doc interface{}) (interface{}, error) {
+				return deleteYamlGeneric(doc, segs)
+			})
+		}
+
+		var parent *yamlNode
+		var parentEntry *yamlEntry
+		node := root
+		for i, seg := range segs {
+			idx, found := node.find(seg)
+			if !found {
+				return fmt.Errorf("%s: %w", strings.Join(segs[:i+1], "."), ErrPathNotFound)
+			}
+			entry := node.entries[idx]
+			if i == len(segs)-1 {
+				if len(node.entries) == 1 && parent != nil {
+					empty := interface{}(map[string]interface{}{})
+					if node.kind == yamlSequenceNode {
+						empty = []interface{}{}
+					}
+					return e.replaceValue(parent, parentEntry, empty)
+				}
+				e.removeEntry(node, idx)
+				return nil
+			}
+			if entry.value == nil {
+				return e.editInline(node, entry, segs[i+1:], nil, true)
+			}
+			parent, parentEntry, node = node, entry, entry.value
+		}
+		return nil
+	})
+}
+
+// edit runs fn, undoing its changes if it fails or leaves invalid YAML.
+func (e *YamlEditor) edit(fn func() error) error {
+	saved := e.data
+	err := fn()
+	if err == nil {
+		var doc interface{}
+		if uerr := yaml.Unmarshal(e.data, &doc); uerr != nil {
+			err = fmt.Errorf("edit would produce invalid YAML: %v", uerr)
+		}
+	}
+	if err != nil {
+		e.data = saved
+	}
+	return err
+}
+
+// splice replaces e.data[start:end] with text, whose line breaks are
+// converted to those of the document.
+func (e *YamlEditor) splice(start, end int, text string) {
+	if i := bytes.IndexByte(e.data, '\n'); i > 0 && e.data[i-1] == '\r' {
+		text = strings.Replace(strings.Replace(text, "\r\n", "\n", -1), "\n", "\r\n", -1)
+	}
+	data := make([]byte, 0, len(e.data)-(end-start)+len(text))
+	data = append(data, e.data[:start]...)
+	data = append(data, text...)
+	e.data = append(data, e.data[end:]...)
+}
+
+// insertLine inserts line and a newline at pos, which is at the start of a
+// line or the end of the document.
+func (e *YamlEditor) insertLine(pos int, line string) {
+	if pos > 0 && e.data[pos-1] != '\n' {
+		line = "\n" + line
+	}
+	e.splice(pos, pos, line+"\n")
+}
+
+// rewrite replaces the whole first document with value, keeping the
+// documents after it.
+func (e *YamlEditor) rewrite(value interface{}) error {
+	rawData, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	start, end, inline := e.firstDocument()
+	text := string(rawData)
+	if inline {
+		// the document started on its "---" line
+		text = "---\n" + text
+	}
+	if start > 0 && e.data[start-1] != '\n' {
+		text = "\n" + text
+	}
+	e.splice(start, end, text)
+	return nil
+}
+
+// rewriteGeneric replaces the whole first document with fn applied to its
+// value, for documents whose root is not a block collection.
+func (e *YamlEditor) rewriteGeneric(fn func(doc interface{}) (interface{}, error)) error {
+	start, end, _ := e.firstDocument()
+	var doc interface{}
+	if err := yaml.Unmarshal(e.data[start:end], &doc); err != nil {
+		return err
+	}
+	doc, err := fn(doc)
+	if err != nil {
+		return err
+	}
+	return e.rewrite(doc)
+}
+
+// firstDocument returns where the first document of the stream starts, at its
+// first line that is not blank, a comment, a directive or a bare "---", and
+// where it ends, at the marker of the next document or the end of the data.
+// inline reports whether its first line starts with "---".
+func (e *YamlEditor) firstDocument() (start, end int, inline bool) {
+	start = -1
+	for pos := 0; pos < len(e.data); {
+		next := bytes.IndexByte(e.data[pos:], '\n') + pos + 1
+		if next == pos {
+			next = len(e.data)
+		}
+		line := strings.TrimRight(string(e.data[pos:next]), "\r\n \t")
+		trimmed := strings.TrimLeft(line, " \t")
+		switch {
+		case start < 0 && (trimmed == "" || trimmed[0] == '#' || line == "---" || line[0] == '%'):
+		case start < 0:
+			start, inline = pos, isYamlMarker(line, "---")
+		case isYamlMarker(line, "---") || isYamlMarker(line, "..."):
+			return start, pos, inline
+		}
+		pos = next
+	}
+	if start < 0 {
+		start = len(e.data)
+	}
+	return start, len(e.data), inline
+}
+
+// replaceValue replaces the value of entry, an entry or item of node.
+func (e *YamlEditor) replaceValue(node *yamlNode, entry *yamlEntry, value interface{}) error {
+	text, block, err := renderYamlValue(value)
+	if err != nil {
+		return err
+	}
+	inner := node.indent + 2
+	text = indentYamlLines(text, inner)
+	switch {
+	case block && node.kind == yamlSequenceNode:
+		e.splice(entry.colon, entry.contentEnd(e.data), " "+text)
+	case block:
+		e.splice(entry.colon, entry.contentEnd(e.data), "\n"+strings.Repeat(" ", inner)+text)
+	case entry.value == nil && entry.valueStart < entry.valueEnd:
+		// keep the comment following the value
+		e.splice(entry.valueStart, entry.valueEnd, text)
+	case entry.value == nil:
+		e.splice(entry.colon, entry.colon, " "+text)
+	default:
+		e.splice(entry.colon, entry.contentEnd(e.data), " "+text)
+	}
+	return nil
+}
+
+// editInline sets the value at segs within the inline value of entry or, if
+// remove is true, deletes it.
+func (e *YamlEditor) editInline(node *yamlNode, entry *yamlEntry, segs []string, value interface{}, remove bool) error {
+	if isYamlFlow(e.data[entry.valueStart:entry.valueEnd]) {
+		return e.editFlow(entry.valueStart, segs, value, remove)
+	}
+	value, err := editYamlGeneric(e.data[entry.valueStart:entry.valueEnd], segs, value, remove)
+	if err != nil {
+		return err
+	}
+	return e.replaceValue(node, entry, value)
+}
+
+// editFlow is editInline for the flow collection starting at start. Only the
+// text of the entry at segs changes, so the others keep their formatting and
+// the plain scalars which a re-rendering would quote or mistake for booleans.
+func (e *YamlEditor) editFlow(start int, segs []string, value interface{}, remove bool) error {
+	node := parseYamlFlow(e.data, start)
+	idx, found := node.find(segs[0])
+	if !found {
+		if remove {
+			return fmt.Errorf("%s: %w", segs[0], ErrPathNotFound)
+		}
+		if idx < 0 && node.kind == yamlSequenceNode {
+			return fmt.Errorf("index %s is out of range", segs[0])
+		}
+		text, err := renderYamlFlow(nestYamlValue(segs[1:], value))
+		if err != nil {
+			return err
+		}
+		if node.kind == yamlMappingNode {
+			key, err := renderYamlFlow(segs[0])
+			if err != nil {
+				return err
+			}
+			text = key + ": " + text
+		}
+		pos := node.end
+		if len(node.entries) > 0 {
+			pos = node.entries[len(node.entries)-1].valueEnd
+			text = ", " + text
+		}
+		e.splice(pos, pos, text)
+		return nil
+	}
+
+	entry := node.entries[idx]
+	raw := e.data[entry.valueStart:entry.valueEnd]
+	switch {
+	case len(segs) == 1 && remove:
+		switch {
+		case idx+1 < len(node.entries):
+			e.splice(entry.start, node.entries[idx+1].start, "")
+		case idx > 0:
+			e.splice(node.entries[idx-1].valueEnd, entry.valueEnd, "")
+		default:
+			e.splice(entry.start, entry.valueEnd, "")
+		}
+		return nil
+	case len(segs) > 1 && isYamlFlow(raw):
+		return e.editFlow(entry.valueStart, segs[1:], value, remove)
+	case len(segs) > 1:
+		var err error
+		if value, err = editYamlGeneric(raw, segs[1:], value, remove); err != nil {
+			return err
+		}
+	}
+	text, err := renderYamlFlow(value)
+	if err != nil {
+		return err
+	}
+	if len(raw) == 0 && node.kind == yamlMappingNode {
+		// a key without a value, with or without its ':'
+		text = " " + text
+		if e.data[entry.valueEnd-1] != ':' {
+			text = ":" + text
+		}
+	}
+	e.splice(entry.valueStart, entry.valueEnd, text)
+	return nil
+}
+
+func (e *YamlEditor) insertEntry(node *yamlNode, key string, value interface{}) error {
+	keyText, _, err := renderYamlValue(key)
+	if err != nil {
+		return err
+	}
+	text, block, err := renderYamlValue(value)
+	if err != nil {
+		return err
+	}
+	inner := node.indent + 2
+	line := strings.Repeat(" ", node.indent) + keyText + ":"
+	if block {
+		line += "\n" + strings.Repeat(" ", inner) + indentYamlLines(text, inner)
+	} else {
+		line += " " + indentYamlLines(text, inner)
+	}
+	e.insertLine(node.end, line)
+	return nil
+}
+
+func (e *YamlEditor) appendItem(node *yamlNode, value interface{}) error {
+	text, _, err := renderYamlValue(value)
+	if err != nil {
+		return err
+	}
+	e.insertLine(node.end, strings.Repeat(" ", node.indent)+"- "+indentYamlLines(text, node.indent+2))
+	return nil
+}
+
+func (e *YamlEditor) removeEntry(node *yamlNode, idx int) {
+	entry := node.entries[idx]
+	if entry.start > 0 && e.data[entry.start-1] != '\n' && idx+1 < len(node.entries) {
+		// the entry follows a "- ", so the next one takes its place
+		e.splice(entry.start, node.entries[idx+1].text, "")
+		return
+	}
+	e.splice(entry.start, entry.end, "")
+}
+
+// renderYamlValue marshals v, reporting whether it is a block collection,
+// which must start on a line of its own.
+func renderYamlValue(v interface{}) (string, bool, error) {
+	rawData, err := yaml.Marshal(v)
+	if err != nil {
+		return "", false, err
+	}
+	text := strings.TrimSuffix(string(rawData), "\n")
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array:
+		return text, !strings.HasPrefix(text, "{") && !strings.HasPrefix(text, "["), nil
+	}
+	return text, false, nil
+}
+
+// renderYamlFlow formats v in flow style, as found within [...] and {...}.
+func renderYamlFlow(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		keys := make([]interface{}, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		pairs := make([]string, len(keys))
+		for i, key := range keys {
+			k, err := renderYamlFlow(key)
+			if err != nil {
+				return "", err
+			}
+			val, err := renderYamlFlow(v[key])
+			if err != nil {
+				return "", err
+			}
+			pairs[i] = k + ": " + val
+		}
+		return "{" + strings.Join(pairs, ", ") + "}", nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := renderYamlFlow(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array, reflect.Ptr:
+		// normalize to the generic types handled above
+		rawData, err := yaml.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		var generic interface{}
+		if err := yaml.Unmarshal(rawData, &generic); err != nil {
+			return "", err
+		}
+		if reflect.TypeOf(generic) == rv.Type() {
+			break
+		}
+		return renderYamlFlow(generic)
+	}
+	text, _, err := renderYamlValue(v)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := v.(string); ok && strings.ContainsAny(text, ",[]{}#\n") {
+		return strconv.Quote(s), nil
+	}
+	return text, nil
+}
+
+// indentYamlLines indents every line of text but the first by n spaces.
+func indentYamlLines(text string, n int) string {
+	return strings.Replace(text, "\n", "\n"+strings.Repeat(" ", n), -1)
+}
+
+// nestYamlValue wraps value in a mapping for every segment of segs.
+func nestYamlValue(segs []string, value interface{}) interface{} {
+	for i := len(segs) - 1; i >= 0; i-- {
+		value = map[string]interface{}{segs[i]: value}
+	}
+	return value
+}
+
+func yamlMapKey(m map[interface{}]interface{}, seg string) (interface{}, bool) {
+	if _, ok := m[seg]; ok {
+		return seg, true
+	}
+	for key := range m {
+		if fmt.Sprint(key) == seg {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func yamlIndex(seg string, n int) (int, bool) {
+	idx, err := strconv.Atoi(seg)
+	return idx, err == nil && idx >= 0 && idx < n
+}
+
+// editYamlGeneric decodes raw and sets the value at segs within it or, if
+// remove is true, deletes it.
+func editYamlGeneric(raw []byte, segs []string, value interface{}, remove bool) (interface{}, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	if remove {
+		return deleteYamlGeneric(doc, segs)
+	}
+	return setYamlGeneric(doc, segs, value)
+}
+
+// setYamlGeneric sets the value at segs within the decoded YAML value doc.
+func setYamlGeneric(doc interface{}, segs []string, value interface{}) (interface{}, error) {
+	if len(segs) == 0 {
+		return value, nil
+	}
+	switch v := doc.(type) {
+	case nil:
+		return nestYamlValue(segs, value), nil
+	case map[interface{}]interface{}:
+		key, ok := yamlMapKey(v, segs[0])
+		if !ok {
+			key = segs[0]
+		}
+		elem, err := setYamlGeneric(v[key], segs[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		v[key] = elem
+		return v, nil
+	case []interface{}:
+		idx, ok := yamlIndex(segs[0], len(v))
+		if !ok && idx == len(v) {
+			v, ok = append(v, nil), true
+		}
+		if !ok {
+			return nil, fmt.Errorf("index %s is out of range", segs[0])
+		}
+		elem, err := setYamlGeneric(v[idx], segs[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		v[idx] = elem
+		return v, nil
+	}
+	return nil, fmt.Errorf("cannot set %s within %v", segs[0], doc)
+}
+
+// deleteYamlGeneric deletes the value at segs within the decoded YAML value
+// doc.
+func deleteYamlGeneric(doc interface{}, segs []string) (interface{}, error) {
+	switch v := doc.(type) {
+	case map[interface{}]interface{}:
+		if key, ok := yamlMapKey(v, segs[0]); ok {
+			if len(segs) == 1 {
+				delete(v, key)
+				return v, nil
+			}
+			elem, err := deleteYamlGeneric(v[key], segs[1:])
+			v[key] = elem
+			return v, err
+		}
+	case []interface{}:
+		if idx, ok := yamlIndex(segs[0], len(v)); ok {
+			if len(segs) == 1 {
+				return append(v[:idx:idx], v[idx+1:]...), nil
+			}
+			elem, err := deleteYamlGeneric(v[idx], segs[1:])
+			v[idx] = elem
+			return v, err
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", segs[0], ErrPathNotFound)
+}
+
+type yamlNodeKind int
+
+const (
+	// a scalar or flow collection
+	yamlScalarNode yamlNodeKind = iota
+	yamlMappingNode
+	yamlSequenceNode
+)
+
+// yamlNode is a block node of a YAML document, with byte offsets into it.
+type yamlNode struct {
+	kind yamlNodeKind
+	// the column of its keys or dashes
+	indent  int
+	entries []*yamlEntry
+	// after its last entry
+	end int
+}
+
+// find returns the index of the entry seg names. If there is none, the index
+// is where a sequence item would be appended, or -1.
+func (n *yamlNode) find(seg string) (int, bool) {
+	if n.kind == yamlSequenceNode {
+		idx, ok := yamlIndex(seg, len(n.entries))
+		if !ok && idx != len(n.entries) {
+			idx = -1
+		}
+		return idx, ok
+	}
+	for i, entry := range n.entries {
+		if entry.key == seg {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// yamlEntry is a mapping entry or sequence item.
+type yamlEntry struct {
+	key string
+	// where it starts: its line, unless it follows a "- " on that line
+	start int
+	// its key or dash
+	text int
+	// after its last line
+	end int
+	// just after its ':' or '-'
+	colon int
+	// a block collection value
+	value *yamlNode
+	// otherwise, its value; empty for null
+	valueStart, valueEnd int
+}
+
+// contentEnd returns where the entry ends, before its final line break.
+func (e *yamlEntry) contentEnd(data []byte) int {
+	end := e.end
+	for end > e.colon && (data[end-1] == '\n' || data[end-1] == '\r') {
+		end--
+	}
+	return end
+}
+
+type yamlLine struct {
+	start int
+	// after the line break
+	end int
+	// after the last non-space character
+	textEnd int
+	indent  int
+	// blank or a comment
+	blank bool
+}
+
+type yamlScanner struct {
+	data  []byte
+	lines []yamlLine
+}
+
+// parseYamlTree scans the block structure of the first document in data. It
+// returns nil for empty documents.
+func parseYamlTree(data []byte) *yamlNode {
+	s := &yamlScanner{data: data}
+	for start := 0; start < len(data); {
+		end := bytes.IndexByte(data[start:], '\n')
+		if end < 0 {
+			end = len(data)
+		} else {
+			end += start + 1
+		}
+		textEnd := end
+		for textEnd > start && strings.IndexByte("\r\n \t", data[textEnd-1]) >= 0 {
+			textEnd--
+		}
+		indent := 0
+		for start+indent < textEnd && data[start+indent] == ' ' {
+			indent++
+		}
+		blank := start+indent == textEnd || data[start+indent] == '#'
+		s.lines = append(s.lines, yamlLine{start, end, textEnd, indent, blank})
+		start = end
+	}
+
+	for i, line := range s.lines {
+		text := s.content(i, line.indent)
+		if line.blank || text == "---" || line.indent == 0 && strings.HasPrefix(text, "%") {
+			continue
+		}
+		if isYamlMarker(text, "---") {
+			return &yamlNode{kind: yamlScalarNode}
+		}
+		node, _ := s.parseNode(i, line.indent)
+		return node
+	}
+	return nil
+}
+
+func (s *yamlScanner) content(i, col int) string {
+	return string(s.data[s.lines[i].start+col : s.lines[i].textEnd])
+}
+
+// nextContent returns the first line from i on that is not blank.
+func (s *yamlScanner) nextContent(i int) int {
+	for i < len(s.lines) && s.lines[i].blank {
+		i++
+	}
+	return i
+}
+
+// continuation returns the last line of the value starting on line i, whose
+// further lines are indented deeper than indent.
+func (s *yamlScanner) continuation(i, indent int) int {
+	last := i
+	for j := i + 1; j < len(s.lines); j++ {
+		if s.lines[j].blank {
+			continue
+		}
+		if s.lines[j].indent <= indent {
+			break
+		}
+		last = j
+	}
+	return last
+}
+
+func isYamlItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseNode parses the node starting at column col of line i, returning it
+// and the line following it.
+func (s *yamlScanner) parseNode(i, col int) (*yamlNode, int) {
+	text := s.content(i, col)
+	if isYamlItem(text) {
+		return s.parseCollection(i, col, yamlSequenceNode)
+	}
+	if _, _, ok := splitYAMLKey(text); ok {
+		return s.parseCollection(i, col, yamlMappingNode)
+	}
+	last := s.continuation(i, col-1)
+	return &yamlNode{kind: yamlScalarNode, end: s.lines[last].end}, last + 1
+}
+
+// parseCollection parses the mapping or sequence whose first entry starts at
+// column col of line i and whose further entries are on lines indented by
+// col.
+func (s *yamlScanner) parseCollection(i, col int, kind yamlNodeKind) (*yamlNode, int) {
+	node := &yamlNode{kind: kind, indent: col}
+	j := i
+	for j < len(s.lines) && (j == i || s.lines[j].indent == col) {
+		text := s.content(j, col)
+		textOff := s.lines[j].start + col
+		entry := &yamlEntry{start: textOff, text: textOff}
+		if j != i {
+			entry.start = s.lines[j].start
+		}
+
+		var value string
+		if kind == yamlSequenceNode {
+			if !isYamlItem(text) {
+				break
+			}
+			value = strings.TrimLeft(text[1:], " \t")
+		} else {
+			var ok bool
+			if isYamlItem(text) {
+				break
+			}
+			if entry.key, value, ok = splitYAMLKey(text); !ok {
+				break
+			}
+		}
+		valueOff := textOff + len(text) - len(value)
+		entry.colon = valueOff
+		for entry.colon > textOff && (s.data[entry.colon-1] == ' ' || s.data[entry.colon-1] == '\t') {
+			entry.colon--
+		}
+
+		j = s.parseValue(entry, j, col, value, valueOff, kind == yamlSequenceNode)
+		node.entries = append(node.entries, entry)
+		node.end = entry.end
+		j = s.nextContent(j)
+	}
+	return node, j
+}
+
+// parseValue fills in the value of entry, whose line is j and whose value
+// text value starts at offset valueOff, and returns the line following it.
+func (s *yamlScanner) parseValue(entry *yamlEntry, j, indent int, value string, valueOff int, item bool) int {
+	switch {
+	case value == "" || value[0] == '#':
+		k := s.nextContent(j + 1)
+		if k < len(s.lines) && (s.lines[k].indent > indent ||
+			!item && s.lines[k].indent == indent && isYamlItem(s.content(k, indent))) {
+			child, next := s.parseNode(k, s.lines[k].indent)
+			if child.kind != yamlScalarNode {
+				entry.value, entry.end = child, child.end
+				return next
+			}
+			entry.valueStart, entry.valueEnd = s.lines[k].start+s.lines[k].indent, s.lines[next-1].textEnd
+			entry.end = child.end
+			return next
+		}
+		entry.valueStart, entry.valueEnd = entry.colon, entry.colon
+		entry.end = s.lines[j].end
+		return j + 1
+	case item && (isYamlItem(value) || isYamlKeyLine(value)):
+		// a collection starting on the item's line, as in "- key: value"
+		child, next := s.parseNode(j, valueOff-s.lines[j].start)
+		entry.value, entry.end = child, child.end
+		return next
+	}
+	last := s.continuation(j, indent)
+	entry.valueStart = valueOff
+	if last > j || isYAMLBlockScalar(value) {
+		entry.valueEnd = s.lines[last].textEnd
+	} else {
+		entry.valueEnd = valueOff + yamlInlineValueLen(value)
+	}
+	entry.end = s.lines[last].end
+	return last + 1
+}
+
+func isYamlKeyLine(text string) bool {
+	_, _, ok := splitYAMLKey(text)
+	return ok
+}
+
+// yamlInlineValueLen returns the length of the value starting value, without
+// any comment following it.
+func yamlInlineValueLen(value string) int {
+	switch value[0] {
+	case '"', '\'':
+		if end := closingQuote(value); end >= 0 {
+			return end + 1
+		}
+	case '[', '{':
+		depth := 0
+		for i := 0; i < len(value); i++ {
+			switch value[i] {
+			case '[', '{':
+				depth++
+			case ']', '}':
+				if depth--; depth == 0 {
+					return i + 1
+				}
+			case '"', '\'':
+				end := closingQuote(value[i:])
+				if end < 0 {
+					return len(value)
+				}
+				i += end
+			}
+		}
+	default:
+		if i := strings.Index(value, " #"); i >= 0 {
+			return len(strings.TrimRight(value[:i], " \t"))
+		}
+	}
+	return len(value)
+}
+
+func isYamlFlow(value []byte) bool {
+	return len(value) > 0 && (value[0] == '[' || value[0] == '{')
+}
+
+// parseYamlFlow scans the entries of the flow collection starting at start.
+// Entries start at their key or value, and the node ends at its closing
+// bracket.
+func parseYamlFlow(data []byte, start int) *yamlNode {
+	node := &yamlNode{kind: yamlSequenceNode}
+	if data[start] == '{' {
+		node.kind = yamlMappingNode
+	}
+	i := start + 1
+	for {
+		for i < len(data) && strings.IndexByte(" \t\r\n", data[i]) >= 0 {
+			i++
+		}
+		if i >= len(data) || data[i] == ']' || data[i] == '}' {
+			node.end = i
+			return node
+		}
+		end := i + yamlFlowItemLen(data[i:])
+		item := strings.TrimRight(string(data[i:end]), " \t\r\n")
+		entry := &yamlEntry{start: i, text: i, end: end, valueStart: i, valueEnd: i + len(item)}
+		if node.kind == yamlMappingNode {
+			// line breaks within the entry fold to spaces
+			folded := strings.NewReplacer("\r", " ", "\n", " ").Replace(item)
+			if key, value, ok := splitYAMLKey(folded); ok {
+				entry.key = key
+				entry.valueStart = entry.valueEnd - len(value)
+			} else {
+				entry.key = item
+				entry.valueStart = entry.valueEnd
+			}
+		}
+		node.entries = append(node.entries, entry)
+		if i = end; i < len(data) && data[i] == ',' {
+			i++
+		}
+	}
+}
+
+// yamlFlowItemLen returns the length of the flow collection entry starting
+// item, up to the ',' or bracket ending it.
+func yamlFlowItemLen(item []byte) int {
+	depth := 0
+	for i := 0; i < len(item); i++ {
+		switch c := item[i]; c {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				return i
+			}
+		case '"', '\'':
+			// quotes only open a scalar at its start
+			if i > 0 && strings.IndexByte(" \t\r\n[{,:", item[i-1]) < 0 {
+				continue
+			}
+			end := closingQuote(string(item[i:]))
+			if end < 0 {
+				return len(item)
+			}
+			i += end
+		}
+	}
+	return len(item)
+}
@@ -0,0 +1,209 @@
+package util
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const yamlEditDocument = `# service config
+name: web   # the service name
+replicas: 2
+
+servers:
+  - host: a.example.com  # primary
+    port: 80
+  - host: b.example.com
+    port: 81
+
+labels: {tier: front, "app.io/name": web}
+script: |
+  echo hi
+empty:
+# trailing comment
+`
+
+func TestYamlEditor(t *testing.T) {
+	Convey("With a YAML document loaded for editing", t, func() {
+		e, err := NewYamlEditor([]byte(yamlEditDocument))
+		So(err, ShouldBeNil)
+
+		Convey("Values should be read by path", func() {
+			v, err := e.Get("servers[1].port")
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, 81)
+			v, err = e.Get(`labels["app.io/name"]`)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "web")
+			_, err = e.Get("servers[2]")
+			So(errors.Is(err, ErrPathNotFound), ShouldBeTrue)
+		})
+
+		Convey("Setting a scalar should keep its comment and the rest of the document", func() {
+			So(e.Set("name", "api"), ShouldBeNil)
+			So(e.Set("servers[0].port", 8080), ShouldBeNil)
+			So(e.Set("empty", true), ShouldBeNil)
+			So(string(e.Bytes()), ShouldEqual, `# service config
+name: api   # the service name
+replicas: 2
+
+servers:
+  - host: a.example.com  # primary
+    port: 8080
+  - host: b.example.com
+    port: 81
+
+labels: {tier: front, "app.io/name": web}
+script: |
+  echo hi
+empty: true
+# trailing comment
+`)
+		})
+
+		Convey("New keys and items should be appended in place", func() {
+			So(e.Set("servers[1].tls.enabled", true), ShouldBeNil)
+			So(e.Set("servers[2]", map[string]interface{}{"host": "c.example.com", "port": 82}), ShouldBeNil)
+			So(e.Set("owner.email", "ops@example.com"), ShouldBeNil)
+			So(string(e.Bytes()), ShouldEqual, `# service config
+name: web   # the service name
+replicas: 2
+
+servers:
+  - host: a.example.com  # primary
+    port: 80
+  - host: b.example.com
+    port: 81
+    tls:
+      enabled: true
+  - host: c.example.com
+    port: 82
+
+labels: {tier: front, "app.io/name": web}
+script: |
+  echo hi
+empty:
+owner:
+  email: ops@example.com
+# trailing comment
+`)
+		})
+
+		Convey("Flow entries should be edited in place and block values rewritten whole", func() {
+			So(e.Set("labels.tier", "back"), ShouldBeNil)
+			So(e.Set("script", []string{"a", "b"}), ShouldBeNil)
+			So(e.Set("servers", "none"), ShouldBeNil)
+			So(string(e.Bytes()), ShouldEqual, `# service config
+name: web   # the service name
+replicas: 2
+
+servers: none
+
+labels: {tier: back, "app.io/name": web}
+script:
+  - a
+  - b
+empty:
+# trailing comment
+`)
+		})
+
+		Convey("Deleting should remove whole entries", func() {
+			So(e.Delete("servers[0].host"), ShouldBeNil)
+			So(e.Delete("servers[1]"), ShouldBeNil)
+			So(e.Delete("replicas"), ShouldBeNil)
+			So(e.Delete("labels.tier"), ShouldBeNil)
+			So(string(e.Bytes()), ShouldEqual, `# service config
+name: web   # the service name
+
+servers:
+  - port: 80
+
+labels: {"app.io/name": web}
+script: |
+  echo hi
+empty:
+# trailing comment
+`)
+			So(e.Delete("servers[0].port"), ShouldBeNil)
+			v, err := e.Get("servers[0]")
+			So(err, ShouldBeNil)
+			So(v, ShouldResemble, map[interface{}]interface{}{})
+			So(errors.Is(e.Delete("missing.key"), ErrPathNotFound), ShouldBeTrue)
+		})
+
+		Convey("Edits that cannot be made should leave the document unchanged", func() {
+			So(e.Set("name.first", "x"), ShouldNotBeNil)
+			So(e.Set("servers[5]", "x"), ShouldNotBeNil)
+			So(string(e.Bytes()), ShouldEqual, yamlEditDocument)
+		})
+
+		Convey("Saving and loading should round-trip the document", func() {
+			dir, err := ioutil.TempDir("", "yamledit")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "config.yaml")
+			So(e.Save(path, 0644), ShouldBeNil)
+			loaded, err := LoadYamlEditor(path)
+			So(err, ShouldBeNil)
+			So(string(loaded.Bytes()), ShouldEqual, yamlEditDocument)
+		})
+	})
+
+	Convey("Flow entries that are not edited should keep their text", t, func() {
+		e, err := NewYamlEditor([]byte("a: {x: 1, y: 2}  # point\nb: [on, [1, 2], {k: v}]\n"))
+		So(err, ShouldBeNil)
+		So(e.Set("a.x", 5), ShouldBeNil)
+		So(string(e.Bytes()), ShouldEqual, "a: {x: 5, y: 2}  # point\nb: [on, [1, 2], {k: v}]\n")
+		v, err := e.Get("a.x")
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, 5)
+
+		So(e.Set("a.z", "n"), ShouldBeNil)
+		So(e.Set("b[1][0]", 3), ShouldBeNil)
+		So(e.Set("b[2].k", "w"), ShouldBeNil)
+		So(e.Set("b[3]", 4), ShouldBeNil)
+		So(e.Delete("a.x"), ShouldBeNil)
+		So(e.Delete("b[3]"), ShouldBeNil)
+		So(string(e.Bytes()), ShouldEqual, "a: {y: 2, z: \"n\"}  # point\nb: [on, [3, 2], {k: w}]\n")
+		So(errors.Is(e.Delete("a.x"), ErrPathNotFound), ShouldBeTrue)
+		So(e.Set("b[9]", 1), ShouldNotBeNil)
+	})
+
+	Convey("Only the first document of a stream should be edited", t, func() {
+		e, err := NewYamlEditor([]byte("--- {a: 1}\n---\nb: 2\n"))
+		So(err, ShouldBeNil)
+		So(e.Set("c", 3), ShouldBeNil)
+		So(string(e.Bytes()), ShouldEqual, "---\na: 1\nc: 3\n---\nb: 2\n")
+
+		e, err = NewYamlEditor([]byte("# first\n---\nscalar\n...\n---\nb: 2\n"))
+		So(err, ShouldBeNil)
+		So(e.Set("", map[string]int{"a": 1}), ShouldBeNil)
+		So(string(e.Bytes()), ShouldEqual, "# first\n---\na: 1\n...\n---\nb: 2\n")
+
+		e, err = NewYamlEditor([]byte("a: 1\n---\nb: 2\n"))
+		So(err, ShouldBeNil)
+		So(e.Set("c", 3), ShouldBeNil)
+		So(e.Delete("a"), ShouldBeNil)
+		So(string(e.Bytes()), ShouldEqual, "c: 3\n---\nb: 2\n")
+	})
+
+	Convey("New lines should use the document's line breaks", t, func() {
+		e, err := NewYamlEditor([]byte("a: 1\r\nb: 2\r\n"))
+		So(err, ShouldBeNil)
+		So(e.Set("c", 3), ShouldBeNil)
+		So(e.Set("d.e", []string{"x"}), ShouldBeNil)
+		So(string(e.Bytes()), ShouldEqual, "a: 1\r\nb: 2\r\nc: 3\r\nd:\r\n  e:\r\n  - x\r\n")
+	})
+
+	Convey("An empty document should be filled with block mappings", t, func() {
+		e, err := NewYamlEditor([]byte("# nothing yet"))
+		So(err, ShouldBeNil)
+		So(e.Set("a.b", []int{1, 2}), ShouldBeNil)
+		So(string(e.Bytes()), ShouldEqual, "# nothing yet\na:\n  b:\n  - 1\n  - 2\n")
+	})
+}